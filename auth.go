@@ -0,0 +1,265 @@
+package main
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	// ScopeHealth allows reading /health and /metrics.
+	ScopeHealth = "health"
+	// ScopeRead allows reading job status, history, logs, snapshots and the dashboard.
+	ScopeRead = "read"
+	// ScopeWrite allows running, cancelling, pausing and resuming jobs.
+	ScopeWrite = "write"
+
+	authRealm = "restic-scheduler"
+)
+
+// unconfiguredPublicScopes are allowed without credentials when there is no http_auth block. Only
+// the write scope, which changes what jobs do, is denied so that endpoints that were open before
+// auth was added keep working.
+var unconfiguredPublicScopes = []string{ScopeHealth, ScopeRead}
+
+var (
+	// ErrUnauthorized is returned when a request has no valid credentials.
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrForbidden is returned when a request's credentials don't grant the required scope.
+	ErrForbidden = errors.New("forbidden")
+
+	// AuthScopes are the accepted values for scopes.
+	AuthScopes = NewSetFrom([]string{ScopeHealth, ScopeRead, ScopeWrite})
+)
+
+// HTTPAuth configures the credentials accepted by the HTTP API and the scopes each grants.
+type HTTPAuth struct {
+	// PublicScopes are allowed without credentials. Defaults to health so that health checks and
+	// metrics scrapes keep working. Every other scope must be granted or made public explicitly.
+	PublicScopes *[]string   `hcl:"public_scopes,optional"`
+	Tokens       []AuthToken `hcl:"token,block"`
+	Users        []AuthUser  `hcl:"user,block"`
+}
+
+// AuthToken is a bearer token accepted by the HTTP API.
+type AuthToken struct {
+	Token  string   `hcl:"token"`
+	Scopes []string `hcl:"scopes"`
+}
+
+// AuthUser is a username and password accepted with basic auth by the HTTP API.
+type AuthUser struct {
+	Username string   `hcl:"username"`
+	Password string   `hcl:"password"`
+	Scopes   []string `hcl:"scopes"`
+}
+
+// Validate ensures that all credentials are set and only known scopes are granted.
+func (a HTTPAuth) Validate() error {
+	if a.PublicScopes != nil {
+		if err := validateScopes(*a.PublicScopes); err != nil {
+			return fmt.Errorf("http_auth public_scopes: %w", err)
+		}
+	}
+
+	for i, token := range a.Tokens {
+		if token.Token == "" {
+			return fmt.Errorf("http_auth token %d is empty: %w", i, ErrMissingField)
+		}
+
+		if err := validateScopes(token.Scopes); err != nil {
+			return fmt.Errorf("http_auth token %d: %w", i, err)
+		}
+	}
+
+	for _, user := range a.Users {
+		if user.Username == "" || user.Password == "" {
+			return fmt.Errorf("http_auth user %q needs a username and password: %w", user.Username, ErrMissingField)
+		}
+
+		if err := validateScopes(user.Scopes); err != nil {
+			return fmt.Errorf("http_auth user %s: %w", user.Username, err)
+		}
+	}
+
+	return nil
+}
+
+func validateScopes(scopes []string) error {
+	for _, scope := range scopes {
+		if !AuthScopes.Contains(scope) {
+			return fmt.Errorf("unknown scope %s: %w", scope, ErrInvalidConfigValue)
+		}
+	}
+
+	return nil
+}
+
+// publicScopes returns the scopes allowed without credentials.
+func (a HTTPAuth) publicScopes() []string {
+	if a.PublicScopes == nil {
+		return []string{ScopeHealth}
+	}
+
+	return *a.PublicScopes
+}
+
+// Authorize checks that the request is allowed the scope, either because the scope is public or
+// because the request has a token or user granting it.
+func (a HTTPAuth) Authorize(request *http.Request, scope string) error {
+	if slices.Contains(a.publicScopes(), scope) {
+		return nil
+	}
+
+	var scopes []string
+
+	if bearer, ok := strings.CutPrefix(request.Header.Get("Authorization"), "Bearer "); ok {
+		for _, token := range a.Tokens {
+			if secureEqual(bearer, token.Token) {
+				scopes = token.Scopes
+
+				break
+			}
+		}
+	} else if username, password, ok := request.BasicAuth(); ok {
+		for _, user := range a.Users {
+			if secureEqual(username, user.Username) && secureEqual(password, user.Password) {
+				scopes = user.Scopes
+
+				break
+			}
+		}
+	}
+
+	if scopes == nil {
+		if !a.hasCredentials() {
+			return fmt.Errorf("%w: the %s scope is not public and no credentials are configured", ErrForbidden, scope)
+		}
+
+		return ErrUnauthorized
+	}
+
+	if !slices.Contains(scopes, scope) {
+		return fmt.Errorf("%w: requires the %s scope", ErrForbidden, scope)
+	}
+
+	return nil
+}
+
+// hasCredentials returns true if any tokens or users are configured.
+func (a HTTPAuth) hasCredentials() bool {
+	return len(a.Tokens) > 0 || len(a.Users) > 0
+}
+
+// secureEqual compares two secrets in constant time.
+func secureEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// Authenticator applies the current HTTPAuth config to HTTP requests. The config can be replaced
+// while serving, such as when configuration is reloaded.
+type Authenticator struct {
+	auth atomic.Pointer[HTTPAuth]
+}
+
+// NewAuthenticator returns an Authenticator using the provided config. A nil config allows the health
+// and read scopes without credentials.
+func NewAuthenticator(auth *HTTPAuth) *Authenticator {
+	authenticator := &Authenticator{auth: atomic.Pointer[HTTPAuth]{}}
+	authenticator.SetAuth(auth)
+
+	return authenticator
+}
+
+// SetAuth replaces the config used for new requests. A nil config allows the health and read scopes
+// without credentials.
+func (a *Authenticator) SetAuth(auth *HTTPAuth) {
+	if auth == nil {
+		auth = &HTTPAuth{PublicScopes: &unconfiguredPublicScopes, Tokens: nil, Users: nil}
+	}
+
+	a.auth.Store(auth)
+}
+
+// Require wraps handler so that it is only called for requests allowed the scope.
+func (a *Authenticator) Require(scope string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		auth := a.auth.Load()
+		err := auth.Authorize(request, scope)
+
+		switch {
+		case errors.Is(err, ErrUnauthorized):
+			if len(auth.Users) > 0 {
+				writer.Header().Add("WWW-Authenticate", fmt.Sprintf("Basic realm=%q", authRealm))
+			}
+
+			if len(auth.Tokens) > 0 {
+				writer.Header().Add("WWW-Authenticate", fmt.Sprintf("Bearer realm=%q", authRealm))
+			}
+
+			http.Error(writer, err.Error(), http.StatusUnauthorized)
+		case err != nil:
+			http.Error(writer, err.Error(), http.StatusForbidden)
+		default:
+			handler(writer, request)
+		}
+	}
+}
+
+// HTTPOptions configures how the HTTP API is served.
+type HTTPOptions struct {
+	// TLSCertFile and TLSKeyFile enable TLS when both are set.
+	TLSCertFile string
+	TLSKeyFile  string
+	// TLSClientCAFile requires clients to present a certificate signed by one of its CAs.
+	TLSClientCAFile string
+	// Auth checks the credentials of each request. If nil, the health and read scopes are allowed.
+	Auth *Authenticator
+}
+
+// Validate ensures that TLS is either fully configured or not at all.
+func (o HTTPOptions) Validate() error {
+	if (o.TLSCertFile == "") != (o.TLSKeyFile == "") {
+		return fmt.Errorf("TLS requires both a certificate and key: %w", ErrMissingField)
+	}
+
+	if o.TLSClientCAFile != "" && o.TLSCertFile == "" {
+		return fmt.Errorf("client certificate verification requires TLS: %w", ErrMissingField)
+	}
+
+	return nil
+}
+
+// TLSConfig returns the TLS config for the server, or nil if TLS is not enabled. Certificate and
+// key files are loaded by the server.
+func (o HTTPOptions) TLSConfig() (*tls.Config, error) {
+	if o.TLSCertFile == "" {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12} //nolint:exhaustruct
+
+	if o.TLSClientCAFile != "" {
+		caPEM, err := os.ReadFile(o.TLSClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed reading client CA file %s: %w", o.TLSClientCAFile, err)
+		}
+
+		clientCAs := x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in client CA file %s: %w", o.TLSClientCAFile, ErrInvalidConfigValue)
+		}
+
+		tlsConfig.ClientCAs = clientCAs
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return tlsConfig, nil
+}
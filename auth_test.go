@@ -0,0 +1,214 @@
+package main_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	main "git.iamthefij.com/iamthefij/restic-scheduler"
+)
+
+func TestAuthenticatorRequire(t *testing.T) {
+	t.Parallel()
+
+	auth := &main.HTTPAuth{
+		PublicScopes: nil,
+		Tokens: []main.AuthToken{
+			{Token: "reader-token", Scopes: []string{main.ScopeHealth, main.ScopeRead}},
+			{Token: "writer-token", Scopes: []string{main.ScopeHealth, main.ScopeRead, main.ScopeWrite}},
+		},
+		Users: []main.AuthUser{
+			{Username: "admin", Password: "hunter2", Scopes: []string{main.ScopeWrite}},
+		},
+	}
+
+	noPublic := []string{}
+	allPublic := []string{main.ScopeHealth, main.ScopeRead, main.ScopeWrite}
+
+	cases := []struct {
+		name         string
+		auth         *main.HTTPAuth
+		scope        string
+		bearer       string
+		username     string
+		password     string
+		expectedCode int
+	}{
+		{"No auth configured allows health", nil, main.ScopeHealth, "", "", "", http.StatusOK},
+		{"No auth configured allows read", nil, main.ScopeRead, "", "", "", http.StatusOK},
+		{"No auth configured denies write", nil, main.ScopeWrite, "", "", "", http.StatusForbidden},
+		{
+			"Open access is opt in",
+			&main.HTTPAuth{PublicScopes: &allPublic, Tokens: nil, Users: nil},
+			main.ScopeWrite, "", "", "", http.StatusOK,
+		},
+		{"Health is public by default", auth, main.ScopeHealth, "", "", "", http.StatusOK},
+		{"Read requires credentials", auth, main.ScopeRead, "", "", "", http.StatusUnauthorized},
+		{"Invalid token", auth, main.ScopeRead, "wrong-token", "", "", http.StatusUnauthorized},
+		{"Token with scope", auth, main.ScopeRead, "reader-token", "", "", http.StatusOK},
+		{"Token without scope", auth, main.ScopeWrite, "reader-token", "", "", http.StatusForbidden},
+		{"Token with write scope", auth, main.ScopeWrite, "writer-token", "", "", http.StatusOK},
+		{"User with scope", auth, main.ScopeWrite, "", "admin", "hunter2", http.StatusOK},
+		{"User without scope", auth, main.ScopeRead, "", "admin", "hunter2", http.StatusForbidden},
+		{"User with wrong password", auth, main.ScopeWrite, "", "admin", "hunter3", http.StatusUnauthorized},
+		{
+			"Health not public",
+			&main.HTTPAuth{PublicScopes: &noPublic, Tokens: auth.Tokens, Users: auth.Users},
+			main.ScopeHealth, "", "", "", http.StatusUnauthorized,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+
+			handler := main.NewAuthenticator(c.auth).Require(c.scope, func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if c.bearer != "" {
+				req.Header.Set("Authorization", "Bearer "+c.bearer)
+			}
+
+			if c.username != "" {
+				req.SetBasicAuth(c.username, c.password)
+			}
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			AssertEqual(t, "unexpected status code", c.expectedCode, rr.Code)
+
+			if c.expectedCode == http.StatusUnauthorized && rr.Header().Get("WWW-Authenticate") == "" {
+				t.Error("expected WWW-Authenticate header on unauthorized response")
+			}
+		})
+	}
+}
+
+func TestAuthenticatorSetAuth(t *testing.T) {
+	t.Parallel()
+
+	authenticator := main.NewAuthenticator(nil)
+	handler := authenticator.Require(main.ScopeRead, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	AssertEqual(t, "unexpected status code without auth", http.StatusOK, rr.Code)
+
+	authenticator.SetAuth(&main.HTTPAuth{
+		PublicScopes: nil,
+		Tokens:       []main.AuthToken{{Token: "token", Scopes: []string{main.ScopeRead}}},
+		Users:        []main.AuthUser{},
+	})
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	AssertEqual(t, "unexpected status code after setting auth", http.StatusUnauthorized, rr.Code)
+}
+
+func TestHTTPAuthValidate(t *testing.T) {
+	t.Parallel()
+
+	unknownScope := []string{"admin"}
+
+	cases := []struct {
+		name        string
+		auth        main.HTTPAuth
+		expectedErr error
+	}{
+		{
+			name: "Valid",
+			auth: main.HTTPAuth{
+				PublicScopes: nil,
+				Tokens:       []main.AuthToken{{Token: "token", Scopes: []string{main.ScopeRead}}},
+				Users:        []main.AuthUser{{Username: "user", Password: "pass", Scopes: []string{main.ScopeWrite}}},
+			},
+			expectedErr: nil,
+		},
+		{
+			name: "Empty token",
+			auth: main.HTTPAuth{
+				PublicScopes: nil,
+				Tokens:       []main.AuthToken{{Token: "", Scopes: []string{main.ScopeRead}}},
+				Users:        nil,
+			},
+			expectedErr: main.ErrMissingField,
+		},
+		{
+			name: "Missing password",
+			auth: main.HTTPAuth{
+				PublicScopes: nil,
+				Tokens:       nil,
+				Users:        []main.AuthUser{{Username: "user", Password: "", Scopes: []string{main.ScopeRead}}},
+			},
+			expectedErr: main.ErrMissingField,
+		},
+		{
+			name: "Unknown scope",
+			auth: main.HTTPAuth{
+				PublicScopes: nil,
+				Tokens:       []main.AuthToken{{Token: "token", Scopes: unknownScope}},
+				Users:        nil,
+			},
+			expectedErr: main.ErrInvalidConfigValue,
+		},
+		{
+			name:        "Unknown public scope",
+			auth:        main.HTTPAuth{PublicScopes: &unknownScope, Tokens: nil, Users: nil},
+			expectedErr: main.ErrInvalidConfigValue,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := c.auth.Validate()
+			if !errors.Is(err, c.expectedErr) {
+				t.Errorf("expected %v but found %v", c.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestParseConfigHTTPAuth(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "auth.hcl")
+
+	err := os.WriteFile(path, []byte(`
+http_auth {
+  public_scopes = []
+
+  token {
+    token  = "secret"
+    scopes = ["read"]
+  }
+
+  user {
+    username = "admin"
+    password = "hunter2"
+    scopes   = ["read", "write"]
+  }
+}
+`), 0o600)
+	AssertEqualFail(t, "unexpected error writing config", nil, err)
+
+	config, err := main.ParseConfig(path)
+	AssertEqualFail(t, "unexpected error parsing config", nil, err)
+
+	if config.HTTPAuth == nil {
+		t.Fatal("expected http_auth to be parsed")
+	}
+
+	AssertEqual(t, "unexpected public scopes", []string{}, *config.HTTPAuth.PublicScopes)
+	AssertEqual(t, "unexpected tokens", []main.AuthToken{{Token: "secret", Scopes: []string{"read"}}}, config.HTTPAuth.Tokens)
+	AssertEqual(t, "unexpected user", "admin", config.HTTPAuth.Users[0].Username)
+}
@@ -17,8 +17,11 @@ var ErrNoJobsFound = errors.New("no jobs found and at least one job is required"
 
 // Config is the global configuration for the scheduler containing job configuration.
 type Config struct {
-	DefaultConfig *ResticConfig `hcl:"default_config,block"`
-	Jobs          []Job         `hcl:"job,block"`
+	DefaultConfig     *ResticConfig  `hcl:"default_config,block"`
+	MaxConcurrentJobs int            `hcl:"max_concurrent_jobs,optional"`
+	HTTPAuth          *HTTPAuth      `hcl:"http_auth,block"`
+	Notify            []NotifyConfig `hcl:"notify,block"`
+	Jobs              []Job          `hcl:"job,block"`
 }
 
 // Validate ensures that the scheduler configuration is valid
@@ -42,7 +45,8 @@ func (c Config) Validate() error {
 	return nil
 }
 
-func ParseConfig(path string) ([]Job, error) {
+// ParseConfig reads and validates a single HCL configuration file.
+func ParseConfig(path string) (*Config, error) {
 	var config Config
 
 	ctx := hcl.EvalContext{
@@ -90,17 +94,67 @@ func ParseConfig(path string) ([]Job, error) {
 		return nil, fmt.Errorf("%s: Failed to decode file: %w", path, err)
 	}
 
+	if config.MaxConcurrentJobs < 0 {
+		return nil, fmt.Errorf("%s: max_concurrent_jobs cannot be negative: %w", path, ErrInvalidConfigValue)
+	}
+
+	if config.HTTPAuth != nil {
+		if err := config.HTTPAuth.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	for _, notify := range config.Notify {
+		if err := notify.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
 	if len(config.Jobs) == 0 {
 		log.Printf("%s: No jobs defined in file", path)
 
-		return []Job{}, nil
+		config.Jobs = []Job{}
+
+		return &config, nil
 	}
 
-	for _, job := range config.Jobs {
-		if err := job.Validate(); err != nil {
+	for i := range config.Jobs {
+		if err := config.Jobs[i].Validate(); err != nil {
 			return nil, fmt.Errorf("%s: Invalid job: %w", path, err)
 		}
+
+		config.Jobs[i].resolveLocation()
 	}
 
-	return config.Jobs, nil
+	return &config, nil
+}
+
+// Merge adds the jobs and settings from another config into this one. Settings defined in both
+// configs must have the same value.
+func (c *Config) Merge(other Config) error {
+	if other.MaxConcurrentJobs != 0 {
+		if c.MaxConcurrentJobs != 0 && c.MaxConcurrentJobs != other.MaxConcurrentJobs {
+			return fmt.Errorf(
+				"max_concurrent_jobs is set to both %d and %d: %w",
+				c.MaxConcurrentJobs,
+				other.MaxConcurrentJobs,
+				ErrInvalidConfigValue,
+			)
+		}
+
+		c.MaxConcurrentJobs = other.MaxConcurrentJobs
+	}
+
+	if other.HTTPAuth != nil {
+		if c.HTTPAuth != nil {
+			return fmt.Errorf("http_auth can only be defined once: %w", ErrInvalidConfigValue)
+		}
+
+		c.HTTPAuth = other.HTTPAuth
+	}
+
+	c.Notify = append(c.Notify, other.Notify...)
+	c.Jobs = append(c.Jobs, other.Jobs...)
+
+	return nil
 }
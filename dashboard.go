@@ -0,0 +1,222 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"embed"
+	"html/template"
+	"log"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	// dashboardRunCount is how many recent runs are shown on a job's dashboard page.
+	dashboardRunCount = 25
+	// dashboardSnapshotTimeout limits how long reading snapshots for a job's dashboard page may take.
+	dashboardSnapshotTimeout = 30 * time.Second
+)
+
+var (
+	//go:embed web/*.html
+	webFiles embed.FS
+
+	dashboardTemplates = template.Must(
+		template.New("").Funcs(template.FuncMap{
+			"datetime": formatDateTime,
+			"ago":      formatAgo,
+			"duration": formatDuration,
+		}).ParseFS(webFiles, "web/*.html"),
+	)
+
+	// Latest snapshot summary for each job, read whenever its snapshots are
+	snapshotSummariesLock = sync.Mutex{}
+	snapshotSummaries     = map[string]SnapshotSummary{}
+)
+
+// SnapshotSummary is the number of snapshots of a job and the time of the latest, as of the last
+// time they were read.
+type SnapshotSummary struct {
+	Count  int
+	Latest time.Time
+	ReadAt time.Time
+}
+
+// recordSnapshots stores the summary of the snapshots read for a job.
+func recordSnapshots(jobName string, snapshots []Snapshot) {
+	summary := SnapshotSummary{Count: len(snapshots), Latest: time.Time{}, ReadAt: time.Now()}
+	if len(snapshots) > 0 {
+		summary.Latest = snapshots[len(snapshots)-1].Time
+	}
+
+	snapshotSummariesLock.Lock()
+	defer snapshotSummariesLock.Unlock()
+
+	snapshotSummaries[jobName] = summary
+}
+
+// dashboardJob is a job as shown on the dashboard.
+type dashboardJob struct {
+	Name       string
+	Active     ActiveJob
+	LastResult *JobResult
+	Snapshots  *SnapshotSummary
+}
+
+// jobPage is the content of a job's dashboard page.
+type jobPage struct {
+	Job            dashboardJob
+	Runs           []RunRecord
+	HistoryEnabled bool
+	Snapshots      []Snapshot
+	SnapshotErr    error
+}
+
+// newDashboardJob collects what the dashboard shows about the named job.
+func newDashboardJob(name string, active ActiveJob) dashboardJob {
+	job := dashboardJob{Name: name, Active: active, LastResult: nil, Snapshots: nil}
+
+	jobResultsLock.Lock()
+	if result, ok := jobResults[jobResultKey{jobName: name, jobType: JobTypeBackup}]; ok {
+		job.LastResult = &result
+	}
+	jobResultsLock.Unlock()
+
+	snapshotSummariesLock.Lock()
+	if summary, ok := snapshotSummaries[name]; ok {
+		job.Snapshots = &summary
+	}
+	snapshotSummariesLock.Unlock()
+
+	return job
+}
+
+// recentRuns returns the most recent runs of the named job, newest first. Without a history store,
+// only the last run of each job type is known.
+func recentRuns(name string) []RunRecord {
+	if History != nil {
+		records, err := History.Read(name, dashboardRunCount)
+		if err == nil {
+			return records
+		}
+
+		log.Printf("ERROR: Failed reading run history for dashboard: %v", err)
+	}
+
+	records := []RunRecord{}
+
+	jobResultsLock.Lock()
+	for key, result := range jobResults {
+		if key.jobName == name {
+			records = append(records, NewRunRecord(result))
+		}
+	}
+	jobResultsLock.Unlock()
+
+	slices.SortFunc(records, func(a, b RunRecord) int {
+		return b.StartTime.Compare(a.StartTime)
+	})
+
+	return records
+}
+
+// DashboardHandleFunc renders the dashboard listing every scheduled job.
+func DashboardHandleFunc(writer http.ResponseWriter, _ *http.Request, sched *Scheduler) {
+	active := sched.Active()
+
+	jobs := make([]dashboardJob, 0, len(active.ActiveJobs))
+	for _, name := range active.ActiveJobs {
+		jobs = append(jobs, newDashboardJob(name, active.Jobs[name]))
+	}
+
+	slices.SortFunc(jobs, func(a, b dashboardJob) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+
+	renderDashboard(writer, "index.html", map[string]any{
+		"Jobs":      jobs,
+		"PausedAll": active.PausedAll,
+	})
+}
+
+// DashboardJobHandleFunc renders the dashboard page for the job named in the path, including its
+// recent runs and the snapshots currently in its repository.
+func DashboardJobHandleFunc(writer http.ResponseWriter, request *http.Request, sched *Scheduler) {
+	name := request.PathValue("name")
+
+	job, ok := sched.Job(name)
+	if !ok {
+		http.NotFound(writer, request)
+		return
+	}
+
+	page := jobPage{
+		Job:            newDashboardJob(name, sched.Active().Jobs[name]),
+		Runs:           recentRuns(name),
+		HistoryEnabled: History != nil,
+		Snapshots:      nil,
+		SnapshotErr:    nil,
+	}
+
+	ctx, cancel := context.WithTimeout(request.Context(), dashboardSnapshotTimeout)
+	defer cancel()
+
+	snapshots, err := job.NewRestic().ReadSnapshots(ctx)
+	if err != nil {
+		page.SnapshotErr = err
+	} else {
+		snapshots = job.FilterSnapshots(snapshots)
+		recordSnapshots(name, snapshots)
+		slices.Reverse(snapshots)
+		page.Snapshots = snapshots
+	}
+
+	renderDashboard(writer, "job.html", page)
+}
+
+// renderDashboard renders the named dashboard template with the provided data.
+func renderDashboard(writer http.ResponseWriter, name string, data any) {
+	writer.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	if err := dashboardTemplates.ExecuteTemplate(writer, name, data); err != nil {
+		log.Printf("ERROR: Failed rendering dashboard page %s: %v", name, err)
+		http.Error(writer, "failed rendering page", http.StatusInternalServerError)
+	}
+}
+
+func formatDateTime(t any) string {
+	switch value := t.(type) {
+	case time.Time:
+		if value.IsZero() {
+			return "-"
+		}
+
+		return value.Local().Format(time.DateTime)
+	case *time.Time:
+		if value == nil {
+			return "-"
+		}
+
+		return formatDateTime(*value)
+	default:
+		return "-"
+	}
+}
+
+func formatAgo(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+
+	return formatDuration(time.Since(t)) + " ago"
+}
+
+func formatDuration(d time.Duration) string {
+	if d < time.Second {
+		return d.Round(time.Millisecond).String()
+	}
+
+	return d.Round(time.Second).String()
+}
@@ -0,0 +1,90 @@
+package main_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	main "git.iamthefij.com/iamthefij/restic-scheduler"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDashboard(t *testing.T) {
+	t.Parallel()
+
+	sched := main.NewScheduler()
+
+	err := sched.Start([]main.Job{
+		{ //nolint:exhaustruct
+			Name:     "TestDashboardJob",
+			Schedule: "0 3 * * *",
+			Config:   &main.ResticConfig{Repo: t.TempDir()}, //nolint:exhaustruct
+		},
+		{Name: "TestDashboardDependent", DependsOn: []string{"TestDashboardJob"}}, //nolint:exhaustruct
+	})
+	AssertEqualFail(t, "unexpected error starting scheduler", nil, err)
+
+	t.Cleanup(func() { sched.StopGraceful(0) })
+
+	cases := []struct {
+		name         string
+		path         string
+		handler      func(http.ResponseWriter, *http.Request, *main.Scheduler)
+		jobName      string
+		expectedCode int
+		expected     []string
+	}{
+		{
+			name:         "Index",
+			path:         "/",
+			handler:      main.DashboardHandleFunc,
+			jobName:      "",
+			expectedCode: http.StatusOK,
+			expected: []string{
+				`<a href="/jobs/TestDashboardJob">TestDashboardJob</a>`,
+				"<code>0 3 * * *</code>",
+				"<code>after upstream jobs</code>",
+			},
+		},
+		{
+			name:         "Job",
+			path:         "/jobs/TestDashboardJob",
+			handler:      main.DashboardJobHandleFunc,
+			jobName:      "TestDashboardJob",
+			expectedCode: http.StatusOK,
+			expected: []string{
+				"<h2>TestDashboardJob</h2>",
+				"Recent runs",
+				"Snapshots",
+			},
+		},
+		{
+			name:         "Unknown job",
+			path:         "/jobs/missing",
+			handler:      main.DashboardJobHandleFunc,
+			jobName:      "missing",
+			expectedCode: http.StatusNotFound,
+			expected:     []string{},
+		},
+	}
+
+	for _, c := range cases {
+		testCase := c
+
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodGet, testCase.path, nil)
+			req.SetPathValue("name", testCase.jobName)
+
+			rr := httptest.NewRecorder()
+			testCase.handler(rr, req, sched)
+
+			assert.Equal(t, testCase.expectedCode, rr.Code)
+
+			for _, expected := range testCase.expected {
+				assert.Contains(t, rr.Body.String(), expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	// UpstreamFailureSkip skips a dependent job if any of the jobs it depends on failed.
+	UpstreamFailureSkip = "skip"
+	// UpstreamFailureRun runs a dependent job even if some of the jobs it depends on failed.
+	UpstreamFailureRun = "run"
+
+	// SkipReasonUpstreamFailure is the reason recorded when a run is skipped because a job it depends on failed.
+	SkipReasonUpstreamFailure = "upstream_failure"
+)
+
+var (
+	// ErrDependencyCycle is returned when jobs depend on each other in a cycle.
+	ErrDependencyCycle = errors.New("dependency cycle")
+
+	// UpstreamFailurePolicies are the accepted values for a job's on_upstream_failure.
+	UpstreamFailurePolicies = NewSetFrom([]string{"", UpstreamFailureSkip, UpstreamFailureRun})
+)
+
+// ValidateDependencies ensures that every job named in a depends_on exists and that no jobs depend
+// on each other in a cycle. Because jobs may depend on jobs from other files, this is checked once
+// all configuration has been read.
+func ValidateDependencies(jobs []Job) error {
+	byName := make(map[string]Job, len(jobs))
+	for _, job := range jobs {
+		byName[job.Name] = job
+	}
+
+	for _, job := range jobs {
+		for _, upstream := range job.DependsOn {
+			if _, ok := byName[upstream]; !ok {
+				return fmt.Errorf("job %s depends on unknown job %s: %w", job.Name, upstream, ErrJobNotFound)
+			}
+		}
+	}
+
+	// Depth first search, tracking the jobs on the current path to find cycles
+	visited := Set{}
+	path := []string{}
+
+	var visit func(name string) error
+
+	visit = func(name string) error {
+		if i := slices.Index(path, name); i >= 0 {
+			cycle := append(slices.Clone(path[i:]), name)
+
+			return fmt.Errorf("jobs %s: %w", strings.Join(cycle, " -> "), ErrDependencyCycle)
+		}
+
+		if visited.Contains(name) {
+			return nil
+		}
+
+		path = append(path, name)
+
+		for _, upstream := range byName[name].DependsOn {
+			if err := visit(upstream); err != nil {
+				return err
+			}
+		}
+
+		path = path[:len(path)-1]
+		visited[name] = true
+
+		return nil
+	}
+
+	for _, job := range jobs {
+		if err := visit(job.Name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
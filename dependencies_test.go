@@ -0,0 +1,164 @@
+package main_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	main "git.iamthefij.com/iamthefij/restic-scheduler"
+	"github.com/stretchr/testify/assert"
+)
+
+func dependentJob(name string, dependsOn ...string) main.Job {
+	return main.Job{ //nolint:exhaustruct
+		Name:      name,
+		DependsOn: dependsOn,
+	}
+}
+
+func TestValidateDependencies(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name        string
+		jobs        []main.Job
+		expectedErr error
+	}{
+		{
+			name:        "No dependencies",
+			jobs:        []main.Job{dependentJob("a"), dependentJob("b")},
+			expectedErr: nil,
+		},
+		{
+			name: "Chain and group",
+			jobs: []main.Job{
+				dependentJob("local"),
+				dependentJob("offsite", "local"),
+				dependentJob("db"),
+				dependentJob("cleanup", "offsite", "db"),
+			},
+			expectedErr: nil,
+		},
+		{
+			name:        "Unknown job",
+			jobs:        []main.Job{dependentJob("offsite", "local")},
+			expectedErr: main.ErrJobNotFound,
+		},
+		{
+			name:        "Depends on itself",
+			jobs:        []main.Job{dependentJob("a", "a")},
+			expectedErr: main.ErrDependencyCycle,
+		},
+		{
+			name: "Cycle",
+			jobs: []main.Job{
+				dependentJob("root"),
+				dependentJob("a", "root", "c"),
+				dependentJob("b", "a"),
+				dependentJob("c", "b"),
+			},
+			expectedErr: main.ErrDependencyCycle,
+		},
+	}
+
+	for _, c := range cases {
+		testCase := c
+
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			actual := main.ValidateDependencies(testCase.jobs)
+			if !errors.Is(actual, testCase.expectedErr) {
+				t.Errorf("expected %v but found %v", testCase.expectedErr, actual)
+			}
+		})
+	}
+}
+
+// TestSchedulerDependencies replaces restic and records history, so it can't run in parallel.
+func TestSchedulerDependencies(t *testing.T) {
+	// Backups of the fail repository fail and all other commands succeed
+	fakeRestic(t, "case \"$*\" in\n*\"--repo /repo/fail backup\"*) exit 1 ;;\nesac\n")
+
+	store := recordHistory(t)
+
+	upstreamJob := func(name, repo string) main.Job {
+		return main.Job{ //nolint:exhaustruct
+			Name:     uniqueJobName(name),
+			Schedule: "@daily",
+			Config:   &main.ResticConfig{Passphrase: "shh", Repo: repo}, //nolint:exhaustruct
+		}
+	}
+	downstreamJob := func(name, repo string, dependsOn ...main.Job) main.Job {
+		job := dependentJob(uniqueJobName(name))
+		job.Config = &main.ResticConfig{Passphrase: "shh", Repo: repo} //nolint:exhaustruct
+
+		for _, upstream := range dependsOn {
+			job.DependsOn = append(job.DependsOn, upstream.Name)
+		}
+
+		return job
+	}
+
+	first := upstreamJob("TestDependenciesFirst", "/repo/first")
+	second := upstreamJob("TestDependenciesSecond", "/repo/second")
+	failing := upstreamJob("TestDependenciesFailing", "/repo/fail")
+	afterBoth := downstreamJob("TestDependenciesAfterBoth", "/repo/after-both", first, second)
+	skipped := downstreamJob("TestDependenciesSkipped", "/repo/skipped", failing)
+	runAnyway := downstreamJob("TestDependenciesRunAnyway", "/repo/run-anyway", failing)
+	runAnyway.OnUpstreamFailure = main.UpstreamFailureRun
+
+	sched := main.NewScheduler()
+
+	err := sched.Start([]main.Job{first, second, failing, afterBoth, skipped, runAnyway})
+	AssertEqualFail(t, "unexpected error starting scheduler", nil, err)
+
+	defer sched.StopGraceful(0)
+
+	runAndWait := func(job main.Job) {
+		t.Helper()
+
+		id, err := sched.RunNow(job.Name, main.JobTypeBackup)
+		AssertEqualFail(t, "unexpected error queueing run", nil, err)
+
+		deadline := time.Now().Add(10 * time.Second)
+
+		for time.Now().Before(deadline) {
+			status, err := sched.RunStatus(id)
+			AssertEqualFail(t, "unexpected error getting run status", nil, err)
+
+			if status.State == main.RunStateFinished {
+				return
+			}
+
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		t.Fatalf("timed out waiting for run of %s", job.Name)
+	}
+
+	// The dependent job waits for every job it depends on
+	runAndWait(first)
+
+	records, err := store.Read(afterBoth.Name, 0)
+	AssertEqualFail(t, "unexpected error reading history", nil, err)
+	assert.Empty(t, records, "expected no run until all upstream jobs completed")
+
+	runAndWait(second)
+
+	records = waitForHistory(t, store, afterBoth.Name, 1)
+	AssertEqual(t, "unexpected trigger", main.TriggerUpstream, records[0].Trigger)
+	AssertEqual(t, "unexpected status", main.JobStatusSuccess, records[0].Status)
+
+	// When an upstream job fails, dependent jobs are skipped unless they run anyway
+	runAndWait(failing)
+
+	records = waitForHistory(t, store, skipped.Name, 1)
+	AssertEqual(t, "unexpected status", main.JobStatusSkipped, records[0].Status)
+	AssertEqual(t, "unexpected message", "skipped: "+main.SkipReasonUpstreamFailure, records[0].Message)
+	assert.False(t, records[0].Success)
+
+	records = waitForHistory(t, store, runAnyway.Name, 1)
+	AssertEqual(t, "unexpected trigger", main.TriggerUpstream, records[0].Trigger)
+	AssertEqual(t, "unexpected status", main.JobStatusSuccess, records[0].Status)
+}
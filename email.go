@@ -0,0 +1,487 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	// SMTPSecurityStartTLS upgrades the SMTP connection to TLS with STARTTLS.
+	SMTPSecurityStartTLS = "starttls"
+	// SMTPSecurityTLS connects to the SMTP server with TLS.
+	SMTPSecurityTLS = "tls"
+	// SMTPSecurityNone sends email without TLS.
+	SMTPSecurityNone = "none"
+
+	defaultEmailSubject = "[restic-scheduler] {{.JobName}} {{.JobType}} {{.Event}}"
+	defaultEmailBody    = `Job: {{.JobName}}
+Operation: {{.JobType}}
+Status: {{.Status}}
+Trigger: {{.Trigger}}
+Started: {{.StartTime.Format "2006-01-02 15:04:05 MST"}}
+Duration: {{.Duration}}
+{{- with .SnapshotID}}
+Snapshot: {{.}}{{end}}
+{{- with .Message}}
+Message: {{.}}{{end}}
+{{- with .Error}}
+
+Error:
+{{.}}{{end}}
+{{- range .Tasks}}
+{{- if not .Success}}
+
+Task {{.Name}} failed: {{.Error}}{{end}}{{end}}
+`
+	digestEmailSubject = "[restic-scheduler] Digest: {{.Failures}} of {{.Runs}} runs failed"
+	digestEmailBody    = `Runs from {{.Since.Format "2006-01-02 15:04 MST"}} to {{.Until.Format "2006-01-02 15:04 MST"}}
+{{range .Jobs}}
+{{.Name}}
+{{- range .Types}}
+  {{.JobType}}: {{.Runs}} runs, {{.Failures}} failed, last {{.Last.Status}} at {{.Last.EndTime.Format "2006-01-02 15:04 MST"}}
+  {{- with .Last.Error}}
+    {{.}}{{end}}
+{{- else}}
+  no runs
+{{- end}}
+{{end}}`
+)
+
+var (
+	// SMTPSecurityModes are the accepted values for smtp_security.
+	SMTPSecurityModes = NewSetFrom([]string{SMTPSecurityStartTLS, SMTPSecurityTLS, SMTPSecurityNone})
+
+	// Digests of runs for email notifiers with a digest schedule, sent by digestCron. Both are
+	// replaced by SetNotifiers and guarded by notifiersLock.
+	digests    = []*digest{}
+	digestCron *cron.Cron
+)
+
+// Digest summarizes the runs of jobs between two times.
+type Digest struct {
+	Since    time.Time
+	Until    time.Time
+	Runs     int
+	Failures int
+	Jobs     []DigestJob
+}
+
+// DigestJob summarizes the runs of a job in a digest.
+type DigestJob struct {
+	Name  string
+	Types []DigestJobType
+}
+
+// DigestJobType summarizes the runs of one job type of a job in a digest.
+type DigestJobType struct {
+	JobType  string
+	Runs     int
+	Failures int
+	Last     RunRecord
+}
+
+// NewDigest summarizes the runs of the named jobs between since and until. Jobs without runs are
+// included so that the digest shows they didn't run.
+func NewDigest(since, until time.Time, jobNames []string, runs []RunRecord) Digest {
+	result := Digest{Since: since, Until: until, Runs: 0, Failures: 0, Jobs: []DigestJob{}}
+
+	for _, name := range slices.Sorted(slices.Values(jobNames)) {
+		job := DigestJob{Name: name, Types: []DigestJobType{}}
+
+		for _, run := range runs {
+			if run.JobName != name {
+				continue
+			}
+
+			i := slices.IndexFunc(job.Types, func(t DigestJobType) bool { return t.JobType == run.JobType })
+			if i < 0 {
+				job.Types = append(job.Types, DigestJobType{JobType: run.JobType, Runs: 0, Failures: 0, Last: run})
+				i = len(job.Types) - 1
+			}
+
+			job.Types[i].Runs++
+			result.Runs++
+
+			if !run.Success {
+				job.Types[i].Failures++
+				result.Failures++
+			}
+
+			if !run.EndTime.Before(job.Types[i].Last.EndTime) {
+				job.Types[i].Last = run
+			}
+		}
+
+		slices.SortFunc(job.Types, func(a, b DigestJobType) int {
+			return cmp.Compare(a.JobType, b.JobType)
+		})
+
+		result.Jobs = append(result.Jobs, job)
+	}
+
+	return result
+}
+
+// digest collects the runs to send in the next digest of an email notifier.
+type digest struct {
+	notifier NotifyConfig
+	// jobName is the job the notifier is configured in, or empty if it is for all jobs.
+	jobName string
+	// jobNames are the names of the jobs summarized.
+	jobNames []string
+
+	mu    sync.Mutex
+	since time.Time
+	runs  []RunRecord
+}
+
+// covers returns true if runs of the named job are included in the digest.
+func (d *digest) covers(jobName string) bool {
+	return d.jobName == "" || d.jobName == jobName
+}
+
+// add records a completed run for the next digest.
+func (d *digest) add(run RunRecord) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.runs = append(d.runs, run)
+}
+
+// send summarizes the runs since the last digest and sends them in the background.
+func (d *digest) send() {
+	d.mu.Lock()
+	now := time.Now()
+	summary := NewDigest(d.since, now, d.jobNames, d.runs)
+	d.since = now
+	d.runs = nil
+	d.mu.Unlock()
+
+	notifications.Go(func() {
+		d.notifier.deliver("digest", func(ctx context.Context) error {
+			return d.notifier.SendDigest(ctx, summary)
+		})
+	})
+}
+
+// setDigestsLocked replaces the digests with those of the email notifiers with a digest schedule.
+// Runs collected for notifiers that are unchanged are kept. notifiersLock must be held.
+func setDigestsLocked(global []NotifyConfig, jobs []Job) {
+	allJobNames := make([]string, 0, len(jobs))
+	for _, job := range jobs {
+		allJobNames = append(allJobNames, job.Name)
+	}
+
+	newDigests := []*digest{}
+
+	addDigests := func(notifiers []NotifyConfig, jobName string, jobNames []string) {
+		for _, notifier := range notifiers {
+			if notifier.DigestSchedule == "" {
+				continue
+			}
+
+			newDigest := &digest{
+				notifier: notifier,
+				jobName:  jobName,
+				jobNames: jobNames,
+				mu:       sync.Mutex{},
+				since:    time.Now(),
+				runs:     nil,
+			}
+
+			for _, old := range digests {
+				if old.jobName == jobName && reflect.DeepEqual(old.notifier, notifier) {
+					old.mu.Lock()
+					newDigest.since, newDigest.runs = old.since, old.runs
+					old.mu.Unlock()
+
+					break
+				}
+			}
+
+			newDigests = append(newDigests, newDigest)
+		}
+	}
+
+	addDigests(global, "", allJobNames)
+
+	for _, job := range jobs {
+		addDigests(job.Notify, job.Name, []string{job.Name})
+	}
+
+	if digestCron != nil {
+		digestCron.Stop()
+		digestCron = nil
+	}
+
+	digests = newDigests
+	if len(digests) == 0 {
+		return
+	}
+
+	digestCron = cron.New()
+
+	for _, d := range digests {
+		if _, err := digestCron.AddFunc(d.notifier.DigestSchedule, d.send); err != nil {
+			log.Printf("ERROR: Failed scheduling notification digest: %v", err)
+		}
+	}
+
+	digestCron.Start()
+}
+
+// StopDigests stops sending scheduled digests. Runs collected since the last digests are not sent.
+func StopDigests() {
+	notifiersLock.Lock()
+	defer notifiersLock.Unlock()
+
+	if digestCron != nil {
+		<-digestCron.Stop().Done()
+		digestCron = nil
+	}
+}
+
+// addToDigests records a completed run for each digest that includes its job.
+func addToDigests(run RunRecord) {
+	notifiersLock.Lock()
+	defer notifiersLock.Unlock()
+
+	for _, d := range digests {
+		if d.covers(run.JobName) {
+			d.add(run)
+		}
+	}
+}
+
+// validateEmail ensures that the email configuration is valid.
+func (n NotifyConfig) validateEmail() error {
+	if n.SMTPHost == "" {
+		return fmt.Errorf("notify %s is missing smtp_host: %w", n.Type, ErrMissingField)
+	}
+
+	if n.SMTPPort < 0 {
+		return fmt.Errorf("notify %s smtp_port cannot be negative: %w", n.Type, ErrInvalidConfigValue)
+	}
+
+	if n.SMTPSecurity != "" && !SMTPSecurityModes.Contains(n.SMTPSecurity) {
+		return fmt.Errorf(
+			"notify %s has an invalid smtp_security %q, must be one of %s, %s or %s: %w",
+			n.Type,
+			n.SMTPSecurity,
+			SMTPSecurityStartTLS,
+			SMTPSecurityTLS,
+			SMTPSecurityNone,
+			ErrInvalidConfigValue,
+		)
+	}
+
+	if (n.Username == "") != (n.Password == "") {
+		return fmt.Errorf("notify %s needs both a username and password to authenticate: %w", n.Type, ErrMissingField)
+	}
+
+	if n.From == "" || len(n.To) == 0 {
+		return fmt.Errorf("notify %s needs from and to addresses: %w", n.Type, ErrMissingField)
+	}
+
+	for _, address := range append([]string{n.From}, n.To...) {
+		if _, err := mail.ParseAddress(address); err != nil {
+			return fmt.Errorf("notify %s has an invalid address %q: %w: %w", n.Type, address, err, ErrInvalidConfigValue)
+		}
+	}
+
+	if _, err := parseNotifyTemplate("subject", n.Subject); err != nil {
+		return fmt.Errorf("notify %s has an invalid subject template: %w: %w", n.Type, err, ErrInvalidConfigValue)
+	}
+
+	if n.DigestSchedule != "" {
+		if _, err := cron.ParseStandard(n.DigestSchedule); err != nil {
+			return fmt.Errorf("notify %s has an invalid digest_schedule: %w: %w", n.Type, err, ErrInvalidConfigValue)
+		}
+	}
+
+	return nil
+}
+
+// smtpSecurity returns how the connection to the SMTP server is secured.
+func (n NotifyConfig) smtpSecurity() string {
+	return cmp.Or(n.SMTPSecurity, SMTPSecurityStartTLS)
+}
+
+// smtpPort returns the configured SMTP port or the standard port for the security mode.
+func (n NotifyConfig) smtpPort() int {
+	if n.SMTPPort != 0 {
+		return n.SMTPPort
+	}
+
+	switch n.smtpSecurity() {
+	case SMTPSecurityTLS:
+		return 465 //nolint:mnd
+	case SMTPSecurityNone:
+		return 25 //nolint:mnd
+	default:
+		return 587 //nolint:mnd
+	}
+}
+
+// sendEmail emails the notification.
+func (n NotifyConfig) sendEmail(ctx context.Context, notification Notification) error {
+	subject, err := renderNotifyTemplate("subject", cmp.Or(n.Subject, defaultEmailSubject), notification)
+	if err != nil {
+		return err
+	}
+
+	body, err := renderNotifyTemplate("body", cmp.Or(n.Body, defaultEmailBody), notification)
+	if err != nil {
+		return err
+	}
+
+	return n.sendMail(ctx, subject, body)
+}
+
+// SendDigest emails the digest once.
+func (n NotifyConfig) SendDigest(ctx context.Context, summary Digest) error {
+	ctx, cancel := context.WithTimeout(ctx, n.timeout())
+	defer cancel()
+
+	subject, err := renderNotifyTemplate("subject", digestEmailSubject, summary)
+	if err != nil {
+		return err
+	}
+
+	body, err := renderNotifyTemplate("body", digestEmailBody, summary)
+	if err != nil {
+		return err
+	}
+
+	return n.sendMail(ctx, subject, body)
+}
+
+// renderNotifyTemplate renders the template text with the provided data.
+func renderNotifyTemplate(name, text string, data any) (string, error) {
+	tmpl, err := parseNotifyTemplate(name, text)
+	if err != nil {
+		return "", err
+	}
+
+	out := strings.Builder{}
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", fmt.Errorf("failed rendering %s template: %w", name, err)
+	}
+
+	return out.String(), nil
+}
+
+// sendMail sends an email with the subject and plain text body to the configured addresses.
+func (n NotifyConfig) sendMail(ctx context.Context, subject, body string) error {
+	addr := net.JoinHostPort(n.SMTPHost, strconv.Itoa(n.smtpPort()))
+	tlsConfig := &tls.Config{ServerName: n.SMTPHost, MinVersion: tls.VersionTLS12} //nolint:exhaustruct
+
+	var (
+		conn net.Conn
+		err  error
+	)
+
+	if n.smtpSecurity() == SMTPSecurityTLS {
+		dialer := &tls.Dialer{NetDialer: nil, Config: tlsConfig}
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	} else {
+		dialer := &net.Dialer{} //nolint:exhaustruct
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed connecting to smtp server %s: %w: %w", addr, err, ErrNotify)
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	// Stop waiting on the server if delivery is abandoned
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+
+	client, err := smtp.NewClient(conn, n.SMTPHost)
+	if err != nil {
+		_ = conn.Close()
+
+		return fmt.Errorf("failed starting smtp session with %s: %w: %w", addr, err, ErrNotify)
+	}
+	defer client.Close()
+
+	if n.smtpSecurity() == SMTPSecurityStartTLS {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("failed starting tls with %s: %w: %w", addr, err, ErrNotify)
+		}
+	}
+
+	if n.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", n.Username, n.Password, n.SMTPHost)); err != nil {
+			return fmt.Errorf("failed authenticating with %s: %w: %w", addr, err, ErrNotify)
+		}
+	}
+
+	// Addresses are validated with the config
+	from, _ := mail.ParseAddress(n.From)
+	if err := client.Mail(from.Address); err != nil {
+		return fmt.Errorf("smtp server rejected sender %s: %w: %w", from.Address, err, ErrNotify)
+	}
+
+	for _, to := range n.To {
+		recipient, _ := mail.ParseAddress(to)
+		if err := client.Rcpt(recipient.Address); err != nil {
+			return fmt.Errorf("smtp server rejected recipient %s: %w: %w", recipient.Address, err, ErrNotify)
+		}
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("failed sending email: %w: %w", err, ErrNotify)
+	}
+
+	if _, err := writer.Write(n.message(subject, body)); err != nil {
+		return fmt.Errorf("failed sending email: %w: %w", err, ErrNotify)
+	}
+
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed sending email: %w: %w", err, ErrNotify)
+	}
+
+	if err := client.Quit(); err != nil {
+		return fmt.Errorf("failed closing smtp session: %w: %w", err, ErrNotify)
+	}
+
+	return nil
+}
+
+// message formats the headers and body of an email.
+func (n NotifyConfig) message(subject, body string) []byte {
+	message := bytes.Buffer{}
+
+	fmt.Fprintf(&message, "From: %s\r\n", n.From)
+	fmt.Fprintf(&message, "To: %s\r\n", strings.Join(n.To, ", "))
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", strings.TrimSpace(subject)))
+	fmt.Fprintf(&message, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	message.WriteString("MIME-Version: 1.0\r\n")
+	message.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	message.WriteString("\r\n")
+	message.WriteString(body)
+
+	return message.Bytes()
+}
@@ -0,0 +1,315 @@
+package main_test
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	main "git.iamthefij.com/iamthefij/restic-scheduler"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeMail is an email received by a fake SMTP server.
+type fakeMail struct {
+	auth string
+	from string
+	to   []string
+	data string
+}
+
+// startFakeSMTP starts an SMTP server that accepts every email without TLS and returns its port
+// and a channel of the emails it receives.
+func startFakeSMTP(t *testing.T) (int, <-chan fakeMail) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	AssertEqualFail(t, "unexpected error listening", nil, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	received := make(chan fakeMail, 10)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go serveFakeSMTP(conn, received)
+		}
+	}()
+
+	return listener.Addr().(*net.TCPAddr).Port, received
+}
+
+func serveFakeSMTP(conn net.Conn, received chan<- fakeMail) {
+	text := textproto.NewConn(conn)
+	defer text.Close()
+
+	mail := fakeMail{auth: "", from: "", to: []string{}, data: ""}
+
+	_ = text.PrintfLine("220 localhost ESMTP")
+
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+
+		command, arg, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(command) {
+		case "EHLO", "HELO":
+			_ = text.PrintfLine("250-localhost")
+			_ = text.PrintfLine("250 AUTH PLAIN")
+		case "AUTH":
+			credentials, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(arg, "PLAIN "))
+			mail.auth = string(credentials)
+			_ = text.PrintfLine("235 Authenticated")
+		case "MAIL":
+			mail.from = arg
+			_ = text.PrintfLine("250 OK")
+		case "RCPT":
+			mail.to = append(mail.to, arg)
+			_ = text.PrintfLine("250 OK")
+		case "DATA":
+			_ = text.PrintfLine("354 Send data")
+			data, _ := text.ReadDotBytes()
+			mail.data = string(data)
+			_ = text.PrintfLine("250 OK")
+			received <- mail
+		case "QUIT":
+			_ = text.PrintfLine("221 Bye")
+			return
+		default:
+			_ = text.PrintfLine("502 Not implemented")
+		}
+	}
+}
+
+// receiveMail waits for the fake SMTP server to receive an email.
+func receiveMail(t *testing.T, received <-chan fakeMail) fakeMail {
+	t.Helper()
+
+	select {
+	case mail := <-received:
+		return mail
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for email")
+
+		return fakeMail{} //nolint:exhaustruct
+	}
+}
+
+func TestNotifyEmailValidate(t *testing.T) {
+	t.Parallel()
+
+	valid := main.NotifyConfig{ //nolint:exhaustruct
+		Type:     "email",
+		SMTPHost: "smtp.example.com",
+		From:     "Backups <backups@example.com>",
+		To:       []string{"admin@example.com"},
+	}
+
+	cases := []struct {
+		name        string
+		modify      func(*main.NotifyConfig)
+		expectedErr error
+	}{
+		{"Valid", func(*main.NotifyConfig) {}, nil},
+		{"Missing host", func(n *main.NotifyConfig) { n.SMTPHost = "" }, main.ErrMissingField},
+		{"Missing to", func(n *main.NotifyConfig) { n.To = nil }, main.ErrMissingField},
+		{"Invalid from", func(n *main.NotifyConfig) { n.From = "backups" }, main.ErrInvalidConfigValue},
+		{"Invalid security", func(n *main.NotifyConfig) { n.SMTPSecurity = "ssl" }, main.ErrInvalidConfigValue},
+		{"Username without password", func(n *main.NotifyConfig) { n.Username = "user" }, main.ErrMissingField},
+		{"Invalid subject", func(n *main.NotifyConfig) { n.Subject = "{{.JobName" }, main.ErrInvalidConfigValue},
+		{"Valid digest", func(n *main.NotifyConfig) { n.DigestSchedule = "0 8 * * *" }, nil},
+		{"Invalid digest", func(n *main.NotifyConfig) { n.DigestSchedule = "daily" }, main.ErrInvalidConfigValue},
+		{
+			"Digest on webhook",
+			func(n *main.NotifyConfig) {
+				n.Type = "webhook"
+				n.URL = "https://example.com/hook"
+				n.DigestSchedule = "@daily"
+			},
+			main.ErrInvalidConfigValue,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+
+			notify := valid
+			c.modify(&notify)
+
+			err := notify.Validate()
+			if !errors.Is(err, c.expectedErr) {
+				t.Errorf("expected %v but found %v", c.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestNotifyEmailSend(t *testing.T) {
+	t.Parallel()
+
+	port, received := startFakeSMTP(t)
+
+	notify := main.NotifyConfig{ //nolint:exhaustruct
+		Type:         "email",
+		SMTPHost:     "127.0.0.1",
+		SMTPPort:     port,
+		SMTPSecurity: main.SMTPSecurityNone,
+		Username:     "user",
+		Password:     "hunter2",
+		From:         "Backups <backups@example.com>",
+		To:           []string{"admin@example.com", "Oncall <oncall@example.com>"},
+		Subject:      "{{.JobName}} {{.Event}}",
+	}
+
+	err := notify.Send(context.Background(), main.Notification{
+		Event: main.NotifyEventFailure,
+		RunRecord: main.RunRecord{ //nolint:exhaustruct
+			JobName:   "TestEmailJob",
+			JobType:   main.JobTypeBackup,
+			Status:    main.JobStatusFailure,
+			Error:     "restic failed",
+			StartTime: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			Duration:  time.Minute,
+			Tasks: []main.TaskOutcome{
+				{Name: "dump", Success: false, Error: "exit status 1", Duration: time.Second},
+			},
+		},
+	})
+	AssertEqualFail(t, "unexpected error sending email", nil, err)
+
+	mail := receiveMail(t, received)
+
+	AssertEqual(t, "unexpected auth", "\x00user\x00hunter2", mail.auth)
+	AssertEqual(t, "unexpected sender", "FROM:<backups@example.com>", mail.from)
+	AssertEqual(t, "unexpected recipients", []string{"TO:<admin@example.com>", "TO:<oncall@example.com>"}, mail.to)
+	assert.Contains(t, mail.data, "From: Backups <backups@example.com>\n")
+	assert.Contains(t, mail.data, "To: admin@example.com, Oncall <oncall@example.com>\n")
+	assert.Contains(t, mail.data, "Subject: TestEmailJob failure\n")
+	assert.Contains(t, mail.data, "Status: failure\n")
+	assert.Contains(t, mail.data, "Error:\nrestic failed\n")
+	assert.Contains(t, mail.data, "Task dump failed: exit status 1\n")
+}
+
+func TestNewDigest(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	run := func(jobName, jobType string, success bool, end time.Duration) main.RunRecord {
+		return main.RunRecord{ //nolint:exhaustruct
+			JobName: jobName,
+			JobType: jobType,
+			Success: success,
+			EndTime: start.Add(end),
+		}
+	}
+
+	digest := main.NewDigest(start, start.Add(24*time.Hour), []string{"second", "first", "idle"}, []main.RunRecord{
+		run("first", main.JobTypeBackup, false, time.Hour),
+		run("first", main.JobTypeBackup, true, 2*time.Hour),
+		run("first", main.JobTypeCheck, true, 3*time.Hour),
+		run("second", main.JobTypeBackup, false, time.Hour),
+		run("removed", main.JobTypeBackup, false, time.Hour),
+	})
+
+	AssertEqual(t, "unexpected runs", 4, digest.Runs)
+	AssertEqual(t, "unexpected failures", 2, digest.Failures)
+	AssertEqualFail(t, "unexpected job count", 3, len(digest.Jobs))
+
+	first := digest.Jobs[0]
+	AssertEqual(t, "unexpected job", "first", first.Name)
+	AssertEqualFail(t, "unexpected job type count", 2, len(first.Types))
+	AssertEqual(t, "unexpected job type", main.JobTypeBackup, first.Types[0].JobType)
+	AssertEqual(t, "unexpected runs", 2, first.Types[0].Runs)
+	AssertEqual(t, "unexpected failures", 1, first.Types[0].Failures)
+	assert.True(t, first.Types[0].Last.Success)
+
+	AssertEqual(t, "unexpected job", "idle", digest.Jobs[1].Name)
+	assert.Empty(t, digest.Jobs[1].Types)
+}
+
+func TestNotifyEmailSendDigest(t *testing.T) {
+	t.Parallel()
+
+	port, received := startFakeSMTP(t)
+
+	notify := main.NotifyConfig{ //nolint:exhaustruct
+		Type:         "email",
+		SMTPHost:     "127.0.0.1",
+		SMTPPort:     port,
+		SMTPSecurity: main.SMTPSecurityNone,
+		From:         "backups@example.com",
+		To:           []string{"admin@example.com"},
+	}
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	digest := main.NewDigest(start, start.Add(24*time.Hour), []string{"busy", "idle"}, []main.RunRecord{
+		{JobName: "busy", JobType: main.JobTypeBackup, Success: true, Status: "success", EndTime: start}, //nolint:exhaustruct
+		{ //nolint:exhaustruct
+			JobName: "busy",
+			JobType: main.JobTypeBackup,
+			Success: false,
+			Status:  "failure",
+			Error:   "restic failed",
+			EndTime: start.Add(time.Hour),
+		},
+	})
+
+	err := notify.SendDigest(context.Background(), digest)
+	AssertEqualFail(t, "unexpected error sending digest", nil, err)
+
+	mail := receiveMail(t, received)
+
+	assert.Contains(t, mail.data, "Subject: [restic-scheduler] Digest: 1 of 2 runs failed\n")
+	assert.Contains(t, mail.data, "busy\n  backup: 2 runs, 1 failed, last failure at 2024-01-01 01:00 UTC\n    restic failed\n")
+	assert.Contains(t, mail.data, "idle\n  no runs\n")
+}
+
+// TestNotifyDigest replaces the configured notifiers, so it can't run in parallel.
+func TestNotifyDigest(t *testing.T) {
+	port, received := startFakeSMTP(t)
+
+	main.SetNotifiers([]main.NotifyConfig{
+		{ //nolint:exhaustruct
+			Type:           "email",
+			On:             &[]string{},
+			SMTPHost:       "127.0.0.1",
+			SMTPPort:       port,
+			SMTPSecurity:   main.SMTPSecurityNone,
+			From:           "backups@example.com",
+			To:             []string{"admin@example.com"},
+			DigestSchedule: "@every 1s",
+		},
+	}, []main.Job{{Name: "TestDigestJob"}}) //nolint:exhaustruct
+	t.Cleanup(func() { main.SetNotifiers(nil, nil) })
+
+	for _, success := range []bool{true, false} {
+		main.JobComplete(main.JobResult{ //nolint:exhaustruct
+			JobName: "TestDigestJob",
+			JobType: main.JobTypeBackup,
+			Success: success,
+			Status:  main.JobStatusSuccess,
+		})
+	}
+
+	mail := receiveMail(t, received)
+	assert.Contains(t, mail.data, "Digest: 1 of 2 runs failed")
+
+	// No emails are sent for the runs themselves
+	select {
+	case mail := <-received:
+		assert.NotContains(t, mail.data, "Job: TestDigestJob")
+	default:
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// JobHealth is the health of a single job as reported by /health/all.
+type JobHealth struct {
+	Healthy bool `json:"healthy"`
+	// LastStatus is the status of the last run of each job type that has run since start.
+	LastStatus map[string]string `json:"last_status"`
+	// LastBackup is when the last backup run ended.
+	LastBackup *time.Time `json:"last_backup,omitempty"`
+	// LatestSnapshot is the time of the latest snapshot, as of the last time snapshots were read.
+	LatestSnapshot *time.Time `json:"latest_snapshot,omitempty"`
+	MaxSnapshotAge string     `json:"max_snapshot_age,omitempty"`
+	// Problems describes why the job is unhealthy.
+	Problems []string `json:"problems,omitempty"`
+}
+
+// HealthReport is the health of every scheduled job. It is healthy only if all jobs are.
+type HealthReport struct {
+	Healthy bool                 `json:"healthy"`
+	Jobs    map[string]JobHealth `json:"jobs"`
+}
+
+// MaxSnapshotAgeDuration returns the maximum age of the latest snapshot before the job is
+// unhealthy, or 0 if there is no maximum. The age is expected to have already been validated.
+func (j Job) MaxSnapshotAgeDuration() time.Duration {
+	if j.MaxSnapshotAge == "" {
+		return 0
+	}
+
+	maxAge, _ := time.ParseDuration(j.MaxSnapshotAge)
+
+	return maxAge
+}
+
+// Health evaluates the job as of now. A job is unhealthy if the last run of any job type failed,
+// or if its latest snapshot is older than its max_snapshot_age. A job that hasn't run since start
+// is healthy unless its snapshots are too old.
+func (j Job) Health(now time.Time) JobHealth {
+	health := JobHealth{
+		Healthy:        true,
+		LastStatus:     map[string]string{},
+		LastBackup:     nil,
+		LatestSnapshot: nil,
+		MaxSnapshotAge: j.MaxSnapshotAge,
+		Problems:       nil,
+	}
+
+	results := []JobResult{}
+
+	jobResultsLock.Lock()
+	for key, result := range jobResults {
+		if key.jobName == j.Name {
+			results = append(results, result)
+		}
+	}
+	jobResultsLock.Unlock()
+
+	slices.SortFunc(results, func(a, b JobResult) int {
+		return cmp.Compare(a.JobType, b.JobType)
+	})
+
+	for _, result := range results {
+		health.LastStatus[result.JobType] = result.Status
+
+		if result.JobType == JobTypeBackup {
+			health.LastBackup = optionalTime(result.EndTime)
+		}
+
+		if !result.Success {
+			problem := fmt.Sprintf("last %s run failed", result.JobType)
+			if result.LastError != nil {
+				problem += ": " + result.LastError.Error()
+			} else if result.Message != "" {
+				problem += ": " + result.Message
+			}
+
+			health.Problems = append(health.Problems, problem)
+		}
+	}
+
+	snapshotSummariesLock.Lock()
+	summary, haveSnapshots := snapshotSummaries[j.Name]
+	snapshotSummariesLock.Unlock()
+
+	if haveSnapshots {
+		health.LatestSnapshot = optionalTime(summary.Latest)
+	}
+
+	// Snapshots that have never been read can't be checked. A failure to read them is reported as
+	// the error of the run that tried.
+	if maxAge := j.MaxSnapshotAgeDuration(); maxAge > 0 && haveSnapshots {
+		switch {
+		case summary.Count == 0:
+			health.Problems = append(health.Problems, "no snapshots found and max_snapshot_age is "+j.MaxSnapshotAge)
+		case now.Sub(summary.Latest) > maxAge:
+			health.Problems = append(health.Problems, fmt.Sprintf(
+				"latest snapshot is %s old, more than max_snapshot_age %s",
+				formatDuration(now.Sub(summary.Latest)),
+				j.MaxSnapshotAge,
+			))
+		}
+	}
+
+	health.Healthy = len(health.Problems) == 0
+
+	return health
+}
+
+// Health evaluates every scheduled job.
+func (s *Scheduler) Health() HealthReport {
+	s.mu.Lock()
+	jobs := slices.Clone(s.jobs)
+	s.mu.Unlock()
+
+	now := time.Now()
+	report := HealthReport{Healthy: true, Jobs: make(map[string]JobHealth, len(jobs))}
+
+	for _, job := range jobs {
+		health := job.Health(now)
+		report.Jobs[job.Name] = health
+		report.Healthy = report.Healthy && health.Healthy
+	}
+
+	return report
+}
+
+// HealthAllHandleFunc writes the health of every scheduled job as JSON. The status is 503 if any
+// job is unhealthy.
+func HealthAllHandleFunc(writer http.ResponseWriter, _ *http.Request, sched *Scheduler) {
+	report := sched.Health()
+
+	writer.Header().Set("Content-Type", "application/json")
+
+	if !report.Healthy {
+		writer.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	if err := json.NewEncoder(writer).Encode(report); err != nil {
+		http.Error(writer, "failed to encode health report", http.StatusInternalServerError)
+	}
+}
+
+// LivezHandleFunc reports that the process is alive and serving requests.
+func LivezHandleFunc(writer http.ResponseWriter, _ *http.Request) {
+	_, _ = writer.Write([]byte("ok"))
+}
+
+// ReadyzHandleFunc reports whether the scheduler is running. The status is 503 once it has been
+// stopped.
+func ReadyzHandleFunc(writer http.ResponseWriter, _ *http.Request, sched *Scheduler) {
+	if !sched.Running() {
+		http.Error(writer, "scheduler is not running", http.StatusServiceUnavailable)
+		return
+	}
+
+	_, _ = writer.Write([]byte("ok"))
+}
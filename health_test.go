@@ -0,0 +1,252 @@
+package main_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	main "git.iamthefij.com/iamthefij/restic-scheduler"
+	"github.com/stretchr/testify/assert"
+)
+
+// uniqueJobName returns a job name that no other run of the tests uses, since job results and
+// snapshot summaries are kept globally.
+func uniqueJobName(name string) string {
+	return fmt.Sprintf("%s %d", name, time.Now().UnixNano())
+}
+
+// fakeRestic replaces restic on the PATH with a shell script for the rest of the test. Tests using
+// it can't run in parallel.
+func fakeRestic(t *testing.T, script string) {
+	t.Helper()
+
+	binDir := t.TempDir()
+
+	err := os.WriteFile(filepath.Join(binDir, "restic"), []byte("#!/bin/sh\n"+script), 0o755)
+	AssertEqualFail(t, "unexpected error writing fake restic", nil, err)
+
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// TestJobHealthSnapshotAge replaces restic with a script that lists fixed snapshots, so it can't
+// run in parallel.
+func TestJobHealthSnapshotAge(t *testing.T) {
+	fakeRestic(t, "case \"$*\" in\n"+
+		"*empty*) echo '[]' ;;\n"+
+		"*) echo '[{\"time\":\"2020-01-01T00:00:00Z\",\"id\":\"abc123\",\"short_id\":\"abc\"}]' ;;\n"+
+		"esac\n")
+
+	latest := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name            string
+		repo            string
+		maxSnapshotAge  string
+		now             time.Time
+		expectedHealthy bool
+	}{
+		{"No max age", "/repo/full", "", latest.Add(time.Hour * 48), true},
+		{"Fresh snapshot", "/repo/full", "24h", latest.Add(time.Hour), true},
+		{"Stale snapshot", "/repo/full", "24h", latest.Add(time.Hour * 48), false},
+		{"No snapshots", "/repo/empty", "24h", latest, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			job := main.Job{ //nolint:exhaustruct
+				Name:           uniqueJobName("TestJobHealthSnapshotAge " + c.name),
+				Schedule:       "@daily",
+				Config:         &main.ResticConfig{Repo: c.repo}, //nolint:exhaustruct
+				MaxSnapshotAge: c.maxSnapshotAge,
+			}
+
+			// Snapshots that haven't been read yet can't be checked
+			assert.True(t, job.Health(c.now).Healthy)
+
+			job.RefreshMetrics()
+
+			health := job.Health(c.now)
+			AssertEqual(t, "unexpected health", c.expectedHealthy, health.Healthy)
+			AssertEqual(t, "unexpected problem count", !c.expectedHealthy, len(health.Problems) == 1)
+
+			if c.repo == "/repo/full" {
+				AssertEqual(t, "unexpected latest snapshot", latest, *health.LatestSnapshot)
+			}
+		})
+	}
+}
+
+// TestHealthHandleFuncScheduledJob replaces restic with a script that lists an old snapshot, so it
+// can't run in parallel.
+func TestHealthHandleFuncScheduledJob(t *testing.T) {
+	fakeRestic(t, "echo '[{\"time\":\"2020-01-01T00:00:00Z\",\"id\":\"abc123\",\"short_id\":\"abc\"}]'\n")
+
+	job := main.Job{ //nolint:exhaustruct
+		Name:           uniqueJobName("TestHealthHandleFuncScheduledJob"),
+		Schedule:       "@daily",
+		Config:         &main.ResticConfig{Repo: "/repo/full"}, //nolint:exhaustruct
+		MaxSnapshotAge: "24h",
+	}
+
+	sched := main.NewScheduler()
+
+	err := sched.Start([]main.Job{job})
+	AssertEqualFail(t, "unexpected error starting scheduler", nil, err)
+
+	defer sched.StopGraceful(0)
+
+	type response struct {
+		Healthy  bool
+		Problems []string
+	}
+
+	get := func(jobName string) (int, response) {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/health?job="+url.QueryEscape(jobName), nil)
+		main.HealthHandleFunc(rr, req, sched)
+
+		var body response
+
+		if rr.Code != http.StatusNotFound {
+			assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+		}
+
+		return rr.Code, body
+	}
+
+	// Configured jobs that haven't run yet are healthy rather than unknown
+	code, health := get(job.Name)
+	AssertEqual(t, "unexpected status code", http.StatusOK, code)
+	assert.True(t, health.Healthy)
+
+	// Snapshots older than max_snapshot_age are unhealthy, as they are for /health/all
+	job.RefreshMetrics()
+
+	code, health = get(job.Name)
+	AssertEqual(t, "unexpected status code", http.StatusServiceUnavailable, code)
+	assert.False(t, health.Healthy)
+	AssertEqual(t, "unexpected problem count", 1, len(health.Problems))
+
+	code, _ = get("missing")
+	AssertEqual(t, "unexpected status code", http.StatusNotFound, code)
+}
+
+func TestJobHealthFailedRun(t *testing.T) {
+	t.Parallel()
+
+	job := main.Job{Name: uniqueJobName("TestJobHealthFailedRun"), Schedule: "@daily"} //nolint:exhaustruct
+
+	health := job.Health(time.Now())
+	assert.True(t, health.Healthy)
+	assert.Empty(t, health.LastStatus)
+
+	main.JobComplete(main.JobResult{ //nolint:exhaustruct
+		JobName:   job.Name,
+		JobType:   main.JobTypeCheck,
+		Success:   false,
+		Status:    main.JobStatusFailure,
+		LastError: errors.New("repository is damaged"),
+	})
+
+	health = job.Health(time.Now())
+	assert.False(t, health.Healthy)
+	AssertEqual(t, "unexpected last status", map[string]string{main.JobTypeCheck: main.JobStatusFailure}, health.LastStatus)
+	AssertEqual(t, "unexpected problems", []string{"last check run failed: repository is damaged"}, health.Problems)
+}
+
+func TestJobHealthIgnoresSkippedRuns(t *testing.T) {
+	t.Parallel()
+
+	job := main.Job{Name: uniqueJobName("TestJobHealthIgnoresSkippedRuns"), Schedule: "@daily"} //nolint:exhaustruct
+
+	main.JobComplete(main.JobResult{ //nolint:exhaustruct
+		JobName: job.Name,
+		JobType: main.JobTypeBackup,
+		Success: false,
+		Status:  main.JobStatusFailure,
+	})
+
+	for _, reason := range []string{main.SkipReasonPaused, main.SkipReasonOverlap, main.SkipReasonUpstreamFailure} {
+		job.RecordSkipped(main.JobTypeBackup, reason)
+	}
+
+	health := job.Health(time.Now())
+	assert.False(t, health.Healthy)
+	AssertEqual(t, "unexpected last status", map[string]string{main.JobTypeBackup: main.JobStatusFailure}, health.LastStatus)
+}
+
+func TestHealthAllHandleFunc(t *testing.T) {
+	t.Parallel()
+
+	healthy := uniqueJobName("TestHealthAllHealthy")
+	unhealthy := uniqueJobName("TestHealthAllUnhealthy")
+
+	sched := main.NewScheduler()
+
+	err := sched.Start([]main.Job{
+		{Name: healthy, Schedule: "@daily"},   //nolint:exhaustruct
+		{Name: unhealthy, Schedule: "@daily"}, //nolint:exhaustruct
+	})
+	AssertEqualFail(t, "unexpected error starting scheduler", nil, err)
+
+	defer sched.StopGraceful(0)
+
+	serve := func() (int, main.HealthReport) {
+		rr := httptest.NewRecorder()
+		main.HealthAllHandleFunc(rr, httptest.NewRequest(http.MethodGet, "/health/all", nil), sched)
+
+		report := main.HealthReport{} //nolint:exhaustruct
+		AssertEqualFail(t, "unexpected error decoding report", nil, json.NewDecoder(rr.Body).Decode(&report))
+
+		return rr.Code, report
+	}
+
+	code, report := serve()
+	AssertEqual(t, "unexpected status code before runs", http.StatusOK, code)
+	assert.True(t, report.Healthy)
+	assert.Len(t, report.Jobs, 2)
+
+	main.JobComplete(main.JobResult{ //nolint:exhaustruct
+		JobName: unhealthy,
+		JobType: main.JobTypeBackup,
+		Success: false,
+		Status:  main.JobStatusTimeout,
+	})
+
+	code, report = serve()
+	AssertEqual(t, "unexpected status code after failure", http.StatusServiceUnavailable, code)
+	assert.False(t, report.Healthy)
+	assert.True(t, report.Jobs[healthy].Healthy)
+	assert.False(t, report.Jobs[unhealthy].Healthy)
+}
+
+func TestReadyzHandleFunc(t *testing.T) {
+	t.Parallel()
+
+	sched := main.NewScheduler()
+
+	ready := func() int {
+		rr := httptest.NewRecorder()
+		main.ReadyzHandleFunc(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil), sched)
+
+		return rr.Code
+	}
+
+	AssertEqual(t, "unexpected status before start", http.StatusServiceUnavailable, ready())
+
+	err := sched.Start([]main.Job{{Name: "TestReadyzJob", Schedule: "@daily"}}) //nolint:exhaustruct
+	AssertEqualFail(t, "unexpected error starting scheduler", nil, err)
+
+	AssertEqual(t, "unexpected status after start", http.StatusOK, ready())
+
+	sched.StopGraceful(0)
+
+	AssertEqual(t, "unexpected status after stop", http.StatusServiceUnavailable, ready())
+}
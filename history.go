@@ -0,0 +1,417 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// historyFileName is the name of the JSON lines file run history is kept in within the state dir.
+	historyFileName = "history.jsonl"
+	// defaultHistoryLimit is how many records the history endpoint returns when no limit is provided.
+	defaultHistoryLimit = 20
+	// maxHistoryLineSize is the longest record that will be read back from the history file.
+	maxHistoryLineSize = 1024 * 1024
+	// defaultHistoryMaxRecords is how many records are kept in the history file by default.
+	defaultHistoryMaxRecords = 10000
+	// historyReadChunkSize is how much of the history file is read at a time when reading it from
+	// the end.
+	historyReadChunkSize = 64 * 1024
+)
+
+// History is the store every completed run is recorded to. It is nil, and no history is kept, if
+// no state dir has been configured.
+var History *HistoryStore
+
+// TaskOutcome is the outcome of a single task within a backup run.
+type TaskOutcome struct {
+	Name     string        `json:"name"`
+	Success  bool          `json:"success"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// NewTaskOutcome returns the outcome of a task that started at the provided time and finished now
+// with the provided error.
+func NewTaskOutcome(name string, start time.Time, err error) TaskOutcome {
+	outcome := TaskOutcome{
+		Name:     name,
+		Success:  err == nil,
+		Error:    "",
+		Duration: time.Since(start),
+	}
+
+	if err != nil {
+		outcome.Error = err.Error()
+	}
+
+	return outcome
+}
+
+// RunRecord is the persisted record of a single run of a job.
+type RunRecord struct {
+	RunID      string        `json:"run_id,omitempty"`
+	JobName    string        `json:"job_name"`
+	JobType    string        `json:"job_type"`
+	Trigger    string        `json:"trigger"`
+	Success    bool          `json:"success"`
+	Status     string        `json:"status"`
+	Message    string        `json:"message,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	StartTime  time.Time     `json:"start_time"`
+	EndTime    time.Time     `json:"end_time"`
+	Duration   time.Duration `json:"duration"`
+	QueueWait  time.Duration `json:"queue_wait"`
+	Attempts   int           `json:"attempts"`
+	SnapshotID string        `json:"snapshot_id,omitempty"`
+	Tasks      []TaskOutcome `json:"tasks,omitempty"`
+}
+
+// NewRunRecord builds the record to persist for a job result.
+func NewRunRecord(result JobResult) RunRecord {
+	record := RunRecord{
+		RunID:      result.RunID,
+		JobName:    result.JobName,
+		JobType:    result.JobType,
+		Trigger:    result.Trigger,
+		Success:    result.Success,
+		Status:     result.Status,
+		Message:    result.Message,
+		Error:      "",
+		StartTime:  result.StartTime,
+		EndTime:    result.EndTime,
+		Duration:   result.EndTime.Sub(result.StartTime),
+		QueueWait:  result.QueueWait,
+		Attempts:   result.Attempts,
+		SnapshotID: result.SnapshotID,
+		Tasks:      result.Tasks,
+	}
+
+	if result.LastError != nil {
+		record.Error = result.LastError.Error()
+	}
+
+	return record
+}
+
+// JobResult converts the record back into the result of the run it records.
+func (r RunRecord) JobResult() JobResult {
+	var lastErr error
+	if r.Error != "" {
+		lastErr = errors.New(r.Error)
+	}
+
+	return JobResult{
+		JobName:    r.JobName,
+		JobType:    r.JobType,
+		Success:    r.Success,
+		Status:     r.Status,
+		LastError:  lastErr,
+		Message:    r.Message,
+		QueueWait:  r.QueueWait,
+		Attempts:   r.Attempts,
+		Trigger:    r.Trigger,
+		StartTime:  r.StartTime,
+		EndTime:    r.EndTime,
+		SnapshotID: r.SnapshotID,
+		Tasks:      r.Tasks,
+		RunID:      r.RunID,
+	}
+}
+
+// HistoryStore persists run records as JSON lines in a file within a state dir.
+type HistoryStore struct {
+	mu         sync.Mutex
+	path       string
+	maxRecords int
+	// count is the number of records appended since the file was last compacted, plus the number
+	// kept by that compaction.
+	count int
+}
+
+// NewHistoryStore returns a store that keeps run history in the provided state dir, creating the
+// dir if needed. Once there are more than maxRecords records, the oldest are removed. A maxRecords
+// of 0 or less keeps every record.
+func NewHistoryStore(stateDir string, maxRecords int) (*HistoryStore, error) {
+	if err := os.MkdirAll(stateDir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed creating state dir %s: %w", stateDir, err)
+	}
+
+	return &HistoryStore{
+		mu:         sync.Mutex{},
+		path:       filepath.Join(stateDir, historyFileName),
+		maxRecords: maxRecords,
+		count:      0,
+	}, nil
+}
+
+// Append adds a record to the end of the history. The file is compacted once it has grown a tenth
+// past the maximum number of records, so it isn't rewritten on every run.
+func (h *HistoryStore) Append(record RunRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed encoding run record: %w", err)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	file, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o640)
+	if err != nil {
+		return fmt.Errorf("failed opening history file %s: %w", h.path, err)
+	}
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		_ = file.Close()
+
+		return fmt.Errorf("failed writing to history file %s: %w", h.path, err)
+	}
+
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed closing history file %s: %w", h.path, err)
+	}
+
+	h.count++
+
+	if h.maxRecords > 0 && h.count > h.maxRecords+max(h.maxRecords/10, 1) {
+		return h.compactLocked()
+	}
+
+	return nil
+}
+
+// Compact rewrites the history file with only the most recent records, up to the maximum number
+// of records, and drops lines that can't be decoded. It should be called on start, before any
+// records are appended, so a file left over from a larger maximum is trimmed.
+func (h *HistoryStore) Compact() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.compactLocked()
+}
+
+func (h *HistoryStore) compactLocked() error {
+	file, err := os.Open(h.path)
+	if errors.Is(err, os.ErrNotExist) {
+		h.count = 0
+
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed opening history file %s: %w", h.path, err)
+	}
+	defer file.Close()
+
+	lines := [][]byte{}
+
+	// Lines are read newest first, so the ones kept are the most recent
+	err = eachLineReverse(file, func(line []byte) bool {
+		if !json.Valid(line) {
+			log.Printf("Dropping unreadable record from history file %s", h.path)
+
+			return true
+		}
+
+		lines = append(lines, bytes.Clone(line))
+
+		return h.maxRecords <= 0 || len(lines) < h.maxRecords
+	})
+	if err != nil {
+		return fmt.Errorf("failed reading history file %s: %w", h.path, err)
+	}
+
+	// Write to a temporary file and rename it over the history so a crash can't lose records
+	tmp, err := os.CreateTemp(filepath.Dir(h.path), historyFileName+".*")
+	if err != nil {
+		return fmt.Errorf("failed creating temporary history file: %w", err)
+	}
+
+	writer := bufio.NewWriter(tmp)
+
+	for i := len(lines) - 1; i >= 0; i-- {
+		_, _ = writer.Write(lines[i])
+		_ = writer.WriteByte('\n')
+	}
+
+	if err := writer.Flush(); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+
+		return fmt.Errorf("failed writing temporary history file %s: %w", tmp.Name(), err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+
+		return fmt.Errorf("failed closing temporary history file %s: %w", tmp.Name(), err)
+	}
+
+	if err := os.Rename(tmp.Name(), h.path); err != nil {
+		_ = os.Remove(tmp.Name())
+
+		return fmt.Errorf("failed replacing history file %s: %w", h.path, err)
+	}
+
+	h.count = len(lines)
+
+	return nil
+}
+
+// Read returns up to limit of the most recent records for the named job, newest first. An empty
+// job name returns records for all jobs and a limit of 0 or less returns all records. The file is
+// read from the end, so only as much of it as is needed to find limit records is read. Lines that
+// can't be decoded, such as one left partially written by a crash, are skipped.
+func (h *HistoryStore) Read(jobName string, limit int) ([]RunRecord, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	records := []RunRecord{}
+
+	file, err := os.Open(h.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return records, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed opening history file %s: %w", h.path, err)
+	}
+	defer file.Close()
+
+	err = eachLineReverse(file, func(line []byte) bool {
+		var record RunRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			log.Printf("Skipping unreadable record in history file %s: %v", h.path, err)
+
+			return true
+		}
+
+		if jobName == "" || record.JobName == jobName {
+			records = append(records, record)
+		}
+
+		return limit <= 0 || len(records) < limit
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed reading history file %s: %w", h.path, err)
+	}
+
+	return records, nil
+}
+
+// eachLineReverse calls fn with each non-empty line of the file, starting from the last, until fn
+// returns false. The line passed to fn is only valid until it returns.
+func eachLineReverse(file *os.File, fn func(line []byte) bool) error {
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed reading file info: %w", err)
+	}
+
+	offset := info.Size()
+	chunk := make([]byte, historyReadChunkSize)
+	// remaining holds the start of the file that has been read but not yet passed to fn. Only its
+	// first line can be incomplete.
+	remaining := []byte{}
+
+	for offset > 0 {
+		size := min(int64(len(chunk)), offset)
+		offset -= size
+
+		if _, err := file.ReadAt(chunk[:size], offset); err != nil {
+			return fmt.Errorf("failed reading file: %w", err)
+		}
+
+		remaining = append(bytes.Clone(chunk[:size]), remaining...)
+
+		for {
+			i := bytes.LastIndexByte(remaining, '\n')
+			if i < 0 {
+				break
+			}
+
+			if line := remaining[i+1:]; len(line) > 0 && !fn(line) {
+				return nil
+			}
+
+			remaining = remaining[:i]
+		}
+
+		if len(remaining) > maxHistoryLineSize {
+			return fmt.Errorf("line longer than %d bytes", maxHistoryLineSize)
+		}
+	}
+
+	if len(remaining) > 0 {
+		fn(remaining)
+	}
+
+	return nil
+}
+
+// HandleFunc serves the run history as JSON, newest first. The `job` query parameter limits
+// records to a single job and `limit` sets how many are returned.
+func (h *HistoryStore) HandleFunc(writer http.ResponseWriter, request *http.Request) {
+	if h == nil {
+		http.Error(writer, "run history is not enabled, set -state-dir to keep it", http.StatusNotFound)
+		return
+	}
+
+	query := request.URL.Query()
+
+	limit := defaultHistoryLimit
+
+	if rawLimit := query.Get("limit"); rawLimit != "" {
+		var err error
+		if limit, err = strconv.Atoi(rawLimit); err != nil || limit < 1 {
+			http.Error(writer, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+	}
+
+	records, err := h.Read(query.Get("job"), limit)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(writer).Encode(records); err != nil {
+		http.Error(writer, "failed writing json for history", http.StatusInternalServerError)
+	}
+}
+
+// RestoreJobResults loads the last result of each job type for each job from the history so health
+// checks and recovery notifications reflect runs from before a restart. Results already recorded
+// since starting are kept and skipped runs are ignored.
+func RestoreJobResults(store *HistoryStore) error {
+	records, err := store.Read("", 0)
+	if err != nil {
+		return err
+	}
+
+	jobResultsLock.Lock()
+	defer jobResultsLock.Unlock()
+
+	// Records are newest first, so the first seen for each key is the latest
+	for _, record := range records {
+		if record.Status == JobStatusSkipped || record.JobResult().stoppedWithScheduler() {
+			continue
+		}
+
+		key := jobResultKey{jobName: record.JobName, jobType: record.JobType}
+		if _, ok := jobResults[key]; !ok {
+			jobResults[key] = record.JobResult()
+		}
+
+		restoreOutcome(record.JobResult())
+	}
+
+	return nil
+}
@@ -0,0 +1,316 @@
+package main_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	main "git.iamthefij.com/iamthefij/restic-scheduler"
+	"github.com/stretchr/testify/assert"
+)
+
+func historyRecord(jobName, status string, start time.Time) main.RunRecord {
+	return main.RunRecord{ //nolint:exhaustruct
+		JobName:   jobName,
+		JobType:   main.JobTypeBackup,
+		Trigger:   main.TriggerSchedule,
+		Success:   status == main.JobStatusSuccess,
+		Status:    status,
+		StartTime: start,
+		EndTime:   start.Add(time.Minute),
+		Duration:  time.Minute,
+	}
+}
+
+func newTestHistory(t *testing.T, records ...main.RunRecord) *main.HistoryStore {
+	t.Helper()
+
+	store, err := main.NewHistoryStore(filepath.Join(t.TempDir(), "state"), 0)
+	AssertEqualFail(t, "unexpected error creating history store", nil, err)
+
+	for _, record := range records {
+		err := store.Append(record)
+		AssertEqualFail(t, "unexpected error appending to history", nil, err)
+	}
+
+	return store
+}
+
+// recordHistory records every completed run to a new history store for the rest of the test. Tests
+// using it can't run in parallel.
+func recordHistory(t *testing.T) *main.HistoryStore {
+	t.Helper()
+
+	store := newTestHistory(t)
+	main.History = store
+
+	t.Cleanup(func() { main.History = nil })
+
+	return store
+}
+
+// waitForHistory waits for the history to have at least count records for the named job and
+// returns them, newest first.
+func waitForHistory(t *testing.T, store *main.HistoryStore, jobName string, count int) []main.RunRecord {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+
+	for {
+		records, err := store.Read(jobName, 0)
+		AssertEqualFail(t, "unexpected error reading history", nil, err)
+
+		if len(records) >= count {
+			return records
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %d runs of %s, found %d", count, jobName, len(records))
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestNewRunRecord(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, 1, 1, 1, 0, 0, 0, time.UTC)
+
+	record := main.NewRunRecord(main.JobResult{
+		JobName:    "test",
+		JobType:    main.JobTypeBackup,
+		Success:    false,
+		Status:     main.JobStatusFailure,
+		LastError:  errors.New("task failed"),
+		Message:    "",
+		QueueWait:  time.Second,
+		Attempts:   2,
+		Trigger:    main.TriggerCatchUp,
+		StartTime:  start,
+		EndTime:    start.Add(90 * time.Second),
+		SnapshotID: "",
+		Tasks: []main.TaskOutcome{
+			{Name: "backup", Success: false, Error: "task failed", Duration: time.Minute},
+		},
+	})
+
+	AssertEqual(t, "unexpected duration", 90*time.Second, record.Duration)
+	AssertEqual(t, "unexpected error", "task failed", record.Error)
+	AssertEqual(t, "unexpected trigger", main.TriggerCatchUp, record.Trigger)
+	AssertEqual(t, "unexpected task count", 1, len(record.Tasks))
+
+	result := record.JobResult()
+	AssertEqual(t, "unexpected restored error", "task failed", result.LastError.Error())
+	AssertEqual(t, "unexpected restored status", main.JobStatusFailure, result.Status)
+}
+
+func TestHistoryStoreRead(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, 1, 1, 1, 0, 0, 0, time.UTC)
+	store := newTestHistory(
+		t,
+		historyRecord("a", main.JobStatusSuccess, start),
+		historyRecord("b", main.JobStatusSuccess, start.Add(time.Hour)),
+		historyRecord("a", main.JobStatusFailure, start.Add(2*time.Hour)),
+		historyRecord("a", main.JobStatusSuccess, start.Add(3*time.Hour)),
+	)
+
+	cases := []struct {
+		name     string
+		jobName  string
+		limit    int
+		expected []time.Time
+	}{
+		{
+			name:     "All jobs",
+			jobName:  "",
+			limit:    0,
+			expected: []time.Time{start.Add(3 * time.Hour), start.Add(2 * time.Hour), start.Add(time.Hour), start},
+		},
+		{
+			name:     "Single job",
+			jobName:  "a",
+			limit:    0,
+			expected: []time.Time{start.Add(3 * time.Hour), start.Add(2 * time.Hour), start},
+		},
+		{
+			name:     "Limited",
+			jobName:  "a",
+			limit:    2,
+			expected: []time.Time{start.Add(3 * time.Hour), start.Add(2 * time.Hour)},
+		},
+		{
+			name:     "Unknown job",
+			jobName:  "c",
+			limit:    0,
+			expected: []time.Time{},
+		},
+	}
+
+	for _, c := range cases {
+		testCase := c
+
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			records, err := store.Read(testCase.jobName, testCase.limit)
+			AssertEqualFail(t, "unexpected error reading history", nil, err)
+
+			actual := []time.Time{}
+			for _, record := range records {
+				actual = append(actual, record.StartTime.UTC())
+			}
+
+			AssertEqual(t, "unexpected records", testCase.expected, actual)
+		})
+	}
+}
+
+func TestHistoryStoreSkipsUnreadableLines(t *testing.T) {
+	t.Parallel()
+
+	stateDir := t.TempDir()
+
+	store, err := main.NewHistoryStore(stateDir, 0)
+	AssertEqualFail(t, "unexpected error creating history store", nil, err)
+
+	err = store.Append(historyRecord("a", main.JobStatusSuccess, time.Now()))
+	AssertEqualFail(t, "unexpected error appending to history", nil, err)
+
+	// Simulate a record left partially written by a crash
+	file, err := os.OpenFile(filepath.Join(stateDir, "history.jsonl"), os.O_APPEND|os.O_WRONLY, 0o640)
+	AssertEqualFail(t, "unexpected error opening history file", nil, err)
+
+	_, err = file.WriteString(`{"job_name":"a","job_ty`)
+	AssertEqualFail(t, "unexpected error writing history file", nil, err)
+	AssertEqualFail(t, "unexpected error closing history file", nil, file.Close())
+
+	records, err := store.Read("a", 0)
+	AssertEqualFail(t, "unexpected error reading history", nil, err)
+	AssertEqual(t, "unexpected record count", 1, len(records))
+}
+
+func TestHistoryStoreRetention(t *testing.T) {
+	t.Parallel()
+
+	stateDir := t.TempDir()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	store, err := main.NewHistoryStore(stateDir, 100)
+	AssertEqualFail(t, "unexpected error creating history store", nil, err)
+
+	// Enough records that the file is read from the end in more than one chunk
+	for i := range 1000 {
+		err := store.Append(historyRecord("a", main.JobStatusSuccess, start.Add(time.Duration(i)*time.Hour)))
+		AssertEqualFail(t, "unexpected error appending to history", nil, err)
+	}
+
+	records, err := store.Read("a", 0)
+	AssertEqualFail(t, "unexpected error reading history", nil, err)
+
+	if len(records) < 100 || len(records) > 110 {
+		t.Errorf("expected between 100 and 110 records after appending, got %d", len(records))
+	}
+
+	AssertEqual(t, "unexpected newest record", start.Add(999*time.Hour), records[0].StartTime)
+
+	// Compacting drops partially written records and trims to the maximum
+	file, err := os.OpenFile(filepath.Join(stateDir, "history.jsonl"), os.O_APPEND|os.O_WRONLY, 0o640)
+	AssertEqualFail(t, "unexpected error opening history file", nil, err)
+
+	_, err = file.WriteString(`{"job_name":"a","job_ty`)
+	AssertEqualFail(t, "unexpected error writing history file", nil, err)
+	AssertEqualFail(t, "unexpected error closing history file", nil, file.Close())
+
+	AssertEqualFail(t, "unexpected error compacting history", nil, store.Compact())
+
+	records, err = store.Read("a", 0)
+	AssertEqualFail(t, "unexpected error reading history", nil, err)
+	AssertEqualFail(t, "unexpected record count", 100, len(records))
+	AssertEqual(t, "unexpected newest record", start.Add(999*time.Hour), records[0].StartTime)
+	AssertEqual(t, "unexpected oldest record", start.Add(900*time.Hour), records[99].StartTime)
+
+	records, err = store.Read("a", 3)
+	AssertEqualFail(t, "unexpected error reading history", nil, err)
+	AssertEqualFail(t, "unexpected limited record count", 3, len(records))
+	AssertEqual(t, "unexpected oldest limited record", start.Add(997*time.Hour), records[2].StartTime)
+}
+
+func TestHistoryHandleFunc(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, 1, 1, 1, 0, 0, 0, time.UTC)
+	store := newTestHistory(
+		t,
+		historyRecord("a", main.JobStatusSuccess, start),
+		historyRecord("b", main.JobStatusSuccess, start.Add(time.Hour)),
+		historyRecord("a", main.JobStatusFailure, start.Add(2*time.Hour)),
+	)
+
+	cases := []struct {
+		name          string
+		store         *main.HistoryStore
+		query         string
+		expectedCode  int
+		expectedCount int
+	}{
+		{name: "All", store: store, query: "", expectedCode: http.StatusOK, expectedCount: 3},
+		{name: "Job", store: store, query: "job=a", expectedCode: http.StatusOK, expectedCount: 2},
+		{name: "Limit", store: store, query: "job=a&limit=1", expectedCode: http.StatusOK, expectedCount: 1},
+		{name: "Bad limit", store: store, query: "limit=none", expectedCode: http.StatusBadRequest, expectedCount: 0},
+		{name: "Disabled", store: nil, query: "", expectedCode: http.StatusNotFound, expectedCount: 0},
+	}
+
+	for _, c := range cases {
+		testCase := c
+
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodGet, "/history?"+testCase.query, nil)
+			rr := httptest.NewRecorder()
+
+			testCase.store.HandleFunc(rr, req)
+
+			assert.Equal(t, testCase.expectedCode, rr.Code)
+
+			if testCase.expectedCode != http.StatusOK {
+				return
+			}
+
+			var records []main.RunRecord
+
+			err := json.NewDecoder(rr.Body).Decode(&records)
+			AssertEqualFail(t, "unexpected error decoding history", nil, err)
+			AssertEqual(t, "unexpected record count", testCase.expectedCount, len(records))
+		})
+	}
+}
+
+func TestRestoreJobResults(t *testing.T) {
+	t.Parallel()
+
+	jobName := uniqueJobName("TestRestoreJobResults")
+	start := time.Date(2024, 1, 1, 1, 0, 0, 0, time.UTC)
+
+	skipped := historyRecord(jobName, main.JobStatusSkipped, start.Add(time.Hour))
+	skipped.Success = true
+
+	store := newTestHistory(t, historyRecord(jobName, main.JobStatusFailure, start), skipped)
+
+	err := main.RestoreJobResults(store)
+	AssertEqualFail(t, "unexpected error restoring results", nil, err)
+
+	// The skipped run is newer but the failure is restored as the last result
+	job := main.Job{Name: jobName, Schedule: "@daily"} //nolint:exhaustruct
+	health := job.Health(time.Now())
+	assert.False(t, health.Healthy)
+	AssertEqual(t, "unexpected last status", map[string]string{main.JobTypeBackup: main.JobStatusFailure}, health.LastStatus)
+}
@@ -1,11 +1,17 @@
 package main
 
 import (
+	"cmp"
+	"context"
 	"errors"
 	"fmt"
 	"log"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/robfig/cron/v3"
 )
@@ -15,11 +21,40 @@ var (
 	ErrMutuallyExclusive  = errors.New("mutually exclusive values not valid")
 	ErrInvalidConfigValue = errors.New("invalid config value")
 
+	// ScheduledJobTypes are the job types that may each have their own schedule.
+	ScheduledJobTypes = []string{JobTypeBackup, JobTypeForget, JobTypeCheck, JobTypeRestoreVerify}
+
+	// OverlapPolicies are the valid values for a Job's overlap setting.
+	OverlapPolicies = NewSetFrom([]string{"", OverlapAllow, OverlapSkip, OverlapQueue})
+
 	// JobBaseDir is the root for the creation of restic job dirs. These will generally
 	// house SQL dumps prior to backup and before restoration.
 	JobBaseDir = filepath.Join(os.TempDir(), "restic_scheduler")
 )
 
+const (
+	// OverlapAllow starts a new run of a job even if the previous run is still in progress.
+	OverlapAllow = "allow"
+	// OverlapSkip skips a new run of a job if the previous run is still in progress.
+	OverlapSkip = "skip"
+	// OverlapQueue delays a new run of a job until the previous run has finished.
+	OverlapQueue = "queue"
+
+	// JobTypeBackup runs the job's tasks and backs up its files.
+	JobTypeBackup = "backup"
+	// JobTypeForget forgets, and optionally prunes, snapshots according to the job's forget config.
+	JobTypeForget = "forget"
+	// JobTypeCheck checks the job's repository for errors.
+	JobTypeCheck = "check"
+	// JobTypeRestoreVerify restores a snapshot into a temporary directory and validates it.
+	JobTypeRestoreVerify = "restore_verify"
+	// JobTypeUnlock removes locks from the job's repository. It is only run on request.
+	JobTypeUnlock = "unlock"
+
+	// stoppedMessage is the message of runs cancelled because the scheduler stopped.
+	stoppedMessage = "stopped with the scheduler"
+)
+
 // ResticConfig is all configuration to be sent to Restic for the job.
 type ResticConfig struct {
 	Repo       string            `hcl:"repo"`
@@ -50,11 +85,33 @@ func (r ResticConfig) Validate() error {
 // Job contains all configuration required to construct and run a backup and restore job.
 type Job struct {
 	Name     string          `hcl:"name,label"`
-	Schedule string          `hcl:"schedule"`
+	Schedule string          `hcl:"schedule,optional"`
+	Overlap  string          `hcl:"overlap,optional"`
+	Timeout  string          `hcl:"timeout,optional"`
+	Jitter   string          `hcl:"jitter,optional"`
+	Timezone string          `hcl:"timezone,optional"`
+	CatchUp  bool            `hcl:"catch_up,optional"`
 	Config   *ResticConfig   `hcl:"config,block"`
 	Tasks    []JobTask       `hcl:"task,block"`
 	Backup   BackupFilesTask `hcl:"backup,block"`
 	Forget   *ForgetOpts     `hcl:"forget,block"`
+	Check    *CheckOpts      `hcl:"check,block"`
+	Retry    *RetryConfig    `hcl:"retry,block"`
+
+	// Maintenance schedules
+	ForgetSchedule string               `hcl:"forget_schedule,optional"`
+	CheckSchedule  string               `hcl:"check_schedule,optional"`
+	VerifyRestore  *VerifyRestoreConfig `hcl:"verify_restore,block"`
+
+	// Maintenance windows
+	AllowedWindows    []TimeWindow `hcl:"allowed_window,block"`
+	Blackouts         []TimeWindow `hcl:"blackout,block"`
+	WindowPolicy      string       `hcl:"window_policy,optional"`
+	CancelAtWindowEnd bool         `hcl:"cancel_at_window_end,optional"`
+
+	// Dependencies
+	DependsOn         []string `hcl:"depends_on,optional"`
+	OnUpstreamFailure string   `hcl:"on_upstream_failure,optional"`
 
 	// Meta Tasks
 	// NOTE: Now that these are also available within a task
@@ -63,9 +120,115 @@ type Job struct {
 	Postgres []JobTaskPostgres `hcl:"postgres,block"`
 	Sqlite   []JobTaskSqlite   `hcl:"sqlite,block"`
 
+	// Health and notifications
+	MaxSnapshotAge string         `hcl:"max_snapshot_age,optional"`
+	Notify         []NotifyConfig `hcl:"notify,block"`
+
 	// Metrics and health
 	healthy bool
 	lastErr error
+
+	// location is the time zone loaded from Timezone when the config is read
+	location *time.Location
+}
+
+// validateSchedule ensures that the job is triggered either by a valid schedule or by the jobs it depends on.
+func (j Job) validateSchedule() error {
+	if len(j.DependsOn) > 0 {
+		if j.Schedule != "" {
+			return fmt.Errorf("job %s may only have one of schedule or depends_on: %w", j.Name, ErrMutuallyExclusive)
+		}
+
+		if !UpstreamFailurePolicies.Contains(j.OnUpstreamFailure) {
+			return fmt.Errorf(
+				"job %s has an invalid on_upstream_failure %q, must be one of %s or %s: %w",
+				j.Name,
+				j.OnUpstreamFailure,
+				UpstreamFailureSkip,
+				UpstreamFailureRun,
+				ErrInvalidConfigValue,
+			)
+		}
+
+		return nil
+	}
+
+	if j.Schedule == "" {
+		return fmt.Errorf("job %s must have a schedule or depends_on: %w", j.Name, ErrMissingField)
+	}
+
+	return j.validateCronSchedule(JobTypeBackup)
+}
+
+// validateMaintenanceSchedules ensures that the forget and check schedules are valid, if set.
+func (j Job) validateMaintenanceSchedules() error {
+	if j.ForgetSchedule != "" {
+		if j.Forget == nil {
+			return fmt.Errorf("job %s has a forget_schedule but is missing a forget block: %w", j.Name, ErrMissingField)
+		}
+
+		if err := j.validateCronSchedule(JobTypeForget); err != nil {
+			return err
+		}
+	}
+
+	if j.CheckSchedule != "" {
+		if err := j.validateCronSchedule(JobTypeCheck); err != nil {
+			return err
+		}
+	}
+
+	if j.VerifyRestore != nil {
+		if err := j.VerifyRestore.Validate(); err != nil {
+			return fmt.Errorf("job %s has an invalid verify_restore: %w", j.Name, err)
+		}
+
+		if err := j.validateCronSchedule(JobTypeRestoreVerify); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (j Job) validateCronSchedule(jobType string) error {
+	schedule, err := j.CronScheduleFor(jobType)
+	if err != nil {
+		return fmt.Errorf("job %s has an invalid %s schedule: %w", j.Name, jobType, err)
+	}
+
+	if _, err := cron.ParseStandard(schedule); err != nil {
+		return fmt.Errorf("job %s has an invalid %s schedule: %w: %w", j.Name, jobType, err, ErrInvalidConfigValue)
+	}
+
+	return nil
+}
+
+func (j Job) validateWindows() error {
+	for _, window := range j.AllowedWindows {
+		if err := window.Validate(); err != nil {
+			return fmt.Errorf("job %s has an invalid allowed_window: %w", j.Name, err)
+		}
+	}
+
+	for _, blackout := range j.Blackouts {
+		if err := blackout.Validate(); err != nil {
+			return fmt.Errorf("job %s has an invalid blackout: %w", j.Name, err)
+		}
+	}
+
+	if !WindowPolicies.Contains(j.WindowPolicy) {
+		return fmt.Errorf(
+			"job %s has an invalid window_policy %q, must be one of %s or %s: %w",
+			j.Name,
+			j.WindowPolicy,
+			WindowPolicyDefer,
+			WindowPolicySkip,
+			ErrInvalidConfigValue,
+		)
+	}
+
+	return nil
 }
 
 func (j Job) validateTasks() error {
@@ -102,8 +265,46 @@ func (j Job) Validate() error {
 		return fmt.Errorf("job is missing name: %w", ErrMissingField)
 	}
 
-	if _, err := cron.ParseStandard(j.Schedule); err != nil {
-		return fmt.Errorf("job %s has an invalid schedule: %w: %w", j.Name, err, ErrInvalidConfigValue)
+	if j.Timezone != "" {
+		if _, err := time.LoadLocation(j.Timezone); err != nil {
+			return fmt.Errorf("job %s has an invalid timezone: %w: %w", j.Name, err, ErrInvalidConfigValue)
+		}
+
+		if strings.HasPrefix(j.Schedule, "CRON_TZ=") || strings.HasPrefix(j.Schedule, "TZ=") {
+			return fmt.Errorf(
+				"job %s may only set a time zone with one of timezone or a schedule prefix: %w",
+				j.Name,
+				ErrMutuallyExclusive,
+			)
+		}
+	}
+
+	if err := j.validateSchedule(); err != nil {
+		return err
+	}
+
+	if err := j.validateMaintenanceSchedules(); err != nil {
+		return err
+	}
+
+	if err := validateDuration("jitter", j.Jitter); err != nil {
+		return fmt.Errorf("job %s has an invalid jitter: %w", j.Name, err)
+	}
+
+	if !OverlapPolicies.Contains(j.Overlap) {
+		return fmt.Errorf(
+			"job %s has an invalid overlap %q, must be one of %s, %s or %s: %w",
+			j.Name,
+			j.Overlap,
+			OverlapAllow,
+			OverlapSkip,
+			OverlapQueue,
+			ErrInvalidConfigValue,
+		)
+	}
+
+	if err := j.validateWindows(); err != nil {
+		return err
 	}
 
 	if j.Config == nil {
@@ -114,6 +315,20 @@ func (j Job) Validate() error {
 		return fmt.Errorf("job %s has invalid config: %w", j.Name, err)
 	}
 
+	if err := validateTimeout("timeout", j.Timeout); err != nil {
+		return fmt.Errorf("job %s has an invalid timeout: %w", j.Name, err)
+	}
+
+	if err := validateDuration("max_snapshot_age", j.MaxSnapshotAge); err != nil {
+		return fmt.Errorf("job %s has an invalid max_snapshot_age: %w", j.Name, err)
+	}
+
+	if j.Retry != nil {
+		if err := j.Retry.Validate(); err != nil {
+			return fmt.Errorf("job %s has an invalid retry config: %w", j.Name, err)
+		}
+	}
+
 	if err := j.validateTasks(); err != nil {
 		return err
 	}
@@ -122,9 +337,102 @@ func (j Job) Validate() error {
 		return fmt.Errorf("job %s has an invalid backup config: %w", j.Name, err)
 	}
 
+	for _, notify := range j.Notify {
+		if err := notify.Validate(); err != nil {
+			return fmt.Errorf("job %s has an invalid notify config: %w", j.Name, err)
+		}
+	}
+
 	return nil
 }
 
+// CronSchedule returns the job's backup schedule with any H tokens replaced by values chosen from
+// a hash of the job name and hostname. This spreads jobs sharing a config template across hosts.
+// If the job has a timezone, the schedule is prefixed so that cron evaluates it in that zone.
+func (j Job) CronSchedule() (string, error) {
+	return j.CronScheduleFor(JobTypeBackup)
+}
+
+// CronScheduleFor returns the schedule for the provided job type in the same way as CronSchedule.
+// An empty schedule is returned for a job type that isn't scheduled.
+func (j Job) CronScheduleFor(jobType string) (string, error) {
+	hostname, _ := os.Hostname()
+
+	spec, seed := j.Schedule, j.Name+"@"+hostname
+	if jobType != JobTypeBackup {
+		seed = j.Name + "/" + jobType + "@" + hostname
+	}
+
+	switch jobType {
+	case JobTypeForget:
+		spec = j.ForgetSchedule
+	case JobTypeCheck:
+		spec = j.CheckSchedule
+	case JobTypeRestoreVerify:
+		spec = ""
+		if j.VerifyRestore != nil {
+			spec = j.VerifyRestore.Schedule
+		}
+	}
+
+	if spec == "" {
+		return "", nil
+	}
+
+	schedule, err := ExpandHashSchedule(spec, seed)
+	if err != nil {
+		return "", err
+	}
+
+	if j.Timezone != "" {
+		schedule = fmt.Sprintf("CRON_TZ=%s %s", j.Timezone, schedule)
+	}
+
+	return schedule, nil
+}
+
+// Location returns the time zone the job is scheduled in. The timezone is expected to have already
+// been validated.
+func (j Job) Location() *time.Location {
+	if j.location != nil {
+		return j.location
+	}
+
+	return j.loadLocation()
+}
+
+// resolveLocation loads the job's time zone once so that Location doesn't read it from the time
+// zone database on every call. The timezone is expected to have already been validated.
+func (j *Job) resolveLocation() {
+	j.location = j.loadLocation()
+}
+
+// loadLocation loads the job's time zone from the time zone database.
+func (j Job) loadLocation() *time.Location {
+	if j.Timezone == "" {
+		return time.Local
+	}
+
+	location, err := time.LoadLocation(j.Timezone)
+	if err != nil {
+		return time.Local
+	}
+
+	return location
+}
+
+// JitterDuration returns the maximum random delay to add before each scheduled run. The jitter is
+// expected to have already been validated.
+func (j Job) JitterDuration() time.Duration {
+	if j.Jitter == "" {
+		return 0
+	}
+
+	jitter, _ := time.ParseDuration(j.Jitter)
+
+	return jitter
+}
+
 // AllTasks returns an ordered list of ExecutableTasks associated with the Job.
 func (j Job) AllTasks() []ExecutableTask {
 	allTasks := []ExecutableTask{}
@@ -188,18 +496,21 @@ func (j Job) BackupPaths() []string {
 	return paths
 }
 
-// RunBackup executes the backup for this current Job.
-func (j Job) RunBackup() error {
+// runBackup runs the backup, appending the outcome of each task run to outcomes. The job's timeout
+// is applied by the caller.
+func (j Job) runBackup(ctx context.Context, outcomes *[]TaskOutcome) error {
 	logger := GetLogger(j.Name)
 	restic := j.NewRestic()
 
-	if err := restic.EnsureInit(); err != nil {
+	if err := restic.EnsureInit(ctx); err != nil {
 		return fmt.Errorf("failed to init restic for job %s: %w", j.Name, err)
 	}
 
 	backupPaths := j.BackupPaths()
 
 	for _, exTask := range j.AllTasks() {
+		setCurrentTask(j.Name, exTask.Name())
+
 		taskCfg := TaskConfig{
 			BackupPaths: backupPaths,
 			Logger:      GetChildLogger(logger, exTask.Name()),
@@ -207,13 +518,24 @@ func (j Job) RunBackup() error {
 			Env:         j.Config.Env,
 		}
 
-		if err := exTask.RunBackup(taskCfg); err != nil {
+		taskStart := time.Now()
+		err := exTask.RunBackup(ctx, taskCfg)
+		*outcomes = append(*outcomes, NewTaskOutcome(exTask.Name(), taskStart, err))
+
+		if err != nil {
 			return fmt.Errorf("failed running job %s: %w", j.Name, err)
 		}
 	}
 
-	if j.Forget != nil {
-		if err := restic.Forget(*j.Forget); err != nil {
+	// Without a schedule of its own, forget runs after every backup
+	if j.Forget != nil && j.ForgetSchedule == "" {
+		setCurrentTask(j.Name, JobTypeForget)
+
+		forgetStart := time.Now()
+		err := restic.Forget(ctx, *j.Forget)
+		*outcomes = append(*outcomes, NewTaskOutcome(JobTypeForget, forgetStart, err))
+
+		if err != nil {
 			return fmt.Errorf("failed forgetting and pruning job %s: %w", j.Name, err)
 		}
 	}
@@ -221,17 +543,99 @@ func (j Job) RunBackup() error {
 	return nil
 }
 
+// RunForget forgets, and optionally prunes, snapshots according to the job's forget config.
+func (j Job) RunForget(ctx context.Context) error {
+	if j.Forget == nil {
+		return fmt.Errorf("job %s has no forget config: %w", j.Name, ErrMissingField)
+	}
+
+	if err := j.NewRestic().Forget(ctx, *j.Forget); err != nil {
+		return fmt.Errorf("failed forgetting and pruning job %s: %w", j.Name, err)
+	}
+
+	return nil
+}
+
+// RunCheck checks the job's repository for errors using the job's check config.
+func (j Job) RunCheck(ctx context.Context) error {
+	checkOpts := CheckOpts{ReadData: false, ReadDataSubset: "", WithCache: false}
+	if j.Check != nil {
+		checkOpts = *j.Check
+	}
+
+	if err := j.NewRestic().Check(ctx, checkOpts); err != nil {
+		return fmt.Errorf("failed checking repository for job %s: %w", j.Name, err)
+	}
+
+	return nil
+}
+
+// RunUnlock removes all locks from the job's repository.
+func (j Job) RunUnlock(ctx context.Context) error {
+	if err := j.NewRestic().Unlock(ctx, UnlockOpts{RemoveAll: true}); err != nil {
+		return fmt.Errorf("failed unlocking repository for job %s: %w", j.Name, err)
+	}
+
+	return nil
+}
+
+// operation returns the function that runs the job type. Backups record the outcomes of the tasks
+// run by their latest attempt in tasks.
+func (j Job) operation(jobType string, tasks *[]TaskOutcome) func(context.Context) error {
+	switch jobType {
+	case JobTypeForget:
+		return j.RunForget
+	case JobTypeCheck:
+		return j.RunCheck
+	case JobTypeRestoreVerify:
+		return j.RunVerifyRestore
+	case JobTypeUnlock:
+		return j.RunUnlock
+	default:
+		return func(ctx context.Context) error {
+			*tasks = nil
+
+			return j.runBackup(ctx, tasks)
+		}
+	}
+}
+
+// runWithRetry runs the provided operation, retrying failures according to the job's retry config.
+// It returns the number of attempts made and the error from the last attempt.
+func (j Job) runWithRetry(ctx context.Context, run func(context.Context) error) (int, error) {
+	for attempt := 1; ; attempt++ {
+		err := run(ctx)
+		if j.Retry == nil || ctx.Err() != nil || !j.Retry.ShouldRetry(attempt, err) {
+			return attempt, err
+		}
+
+		delay := j.Retry.Delay(attempt)
+		j.Logger().Printf("Attempt %d of %d failed, retrying in %s: %s", attempt, j.Retry.Attempts(), delay, err.Error())
+		Metrics.JobRetryCount.WithLabelValues(j.Name).Inc()
+
+		select {
+		case <-ctx.Done():
+			return attempt, errors.Join(err, contextError(ctx))
+		case <-time.After(delay):
+		}
+	}
+}
+
 // Logger returns the logger for this job.
 func (j Job) Logger() *log.Logger {
 	return GetLogger(j.Name)
 }
 
-// RunRestore executes a restore of this job for a provided snapshot.
-func (j Job) RunRestore(snapshot string) error {
+// RunRestore executes a restore of this job for a provided snapshot. If the job has a timeout, any
+// running task or restic command is killed once it is exceeded.
+func (j Job) RunRestore(ctx context.Context, snapshot string) error {
 	logger := j.Logger()
 	restic := j.NewRestic()
 
-	if _, err := restic.RunRestic("snapshots", NoOpts{}); errors.Is(err, ErrRepoNotFound) {
+	ctx, cancel := withOptionalTimeout(ctx, j.Timeout)
+	defer cancel()
+
+	if _, err := restic.RunRestic(ctx, "snapshots", NoOpts{}); errors.Is(err, ErrRepoNotFound) {
 		return fmt.Errorf("no repository or snapshots for job %s: %w", j.Name, err)
 	}
 
@@ -244,7 +648,7 @@ func (j Job) RunRestore(snapshot string) error {
 			RestoreSnapshot: snapshot,
 		}
 
-		if err := exTask.RunRestore(taskCfg); err != nil {
+		if err := exTask.RunRestore(ctx, taskCfg); err != nil {
 			return fmt.Errorf("failed running job %s: %w", j.Name, err)
 		}
 	}
@@ -257,29 +661,90 @@ func (j Job) Healthy() (bool, error) {
 	return j.healthy, j.lastErr
 }
 
+// RunInfo holds details provided by the scheduler about a single run of a job.
+type RunInfo struct {
+	// QueueWait is how long the run waited for its repository to become free.
+	QueueWait time.Duration
+	// Trigger is what caused the run, such as TriggerSchedule or TriggerCatchUp.
+	Trigger string
+	// JobType is the operation to run, such as JobTypeForget. Defaults to JobTypeBackup.
+	JobType string
+	// RunID identifies the run in the queue, if it was queued.
+	RunID string
+}
+
 // Run runs the backup job with it's provided configuration.
 func (j Job) Run() {
+	j.RunWithInfo(context.Background(), RunInfo{QueueWait: 0, Trigger: "", JobType: JobTypeBackup, RunID: ""})
+}
+
+// RunWithInfo runs the operation of the job type in info, including the scheduler provided run
+// details in the result. The run is stopped if ctx is done or once the job's timeout is exceeded.
+// The timeout applies to the whole run, so it also limits retries and the delays between them.
+// The result is recorded and returned.
+func (j Job) RunWithInfo(ctx context.Context, info RunInfo) JobResult {
+	jobType := cmp.Or(info.JobType, JobTypeBackup)
+
 	result := JobResult{
-		JobName:   j.Name,
-		JobType:   "backup",
-		Success:   true,
-		LastError: nil,
-		Message:   "",
+		JobName:    j.Name,
+		JobType:    jobType,
+		Success:    true,
+		Status:     JobStatusSuccess,
+		LastError:  nil,
+		Message:    "",
+		QueueWait:  info.QueueWait,
+		Attempts:   0,
+		Trigger:    info.Trigger,
+		StartTime:  time.Now(),
+		EndTime:    time.Time{},
+		SnapshotID: "",
+		Tasks:      nil,
+		RunID:      info.RunID,
+	}
+
+	j.Logger().Printf("Starting %s run", jobType)
+
+	Metrics.JobOperationStartTime.WithLabelValues(j.Name, jobType).SetToCurrentTime()
+
+	if jobType == JobTypeBackup {
+		Metrics.JobStartTime.WithLabelValues(j.Name).SetToCurrentTime()
+		Metrics.JobQueueWait.WithLabelValues(j.Name).Set(info.QueueWait.Seconds())
 	}
 
-	Metrics.JobStartTime.WithLabelValues(j.Name).SetToCurrentTime()
+	// Backups replace this with the name of each task as they run
+	setCurrentTask(j.Name, jobType)
 
-	if err := j.RunBackup(); err != nil {
+	runCtx, cancel := withOptionalTimeout(ctx, j.Timeout)
+	attempts, err := j.runWithRetry(runCtx, j.operation(jobType, &result.Tasks))
+
+	cancel()
+	setCurrentTask(j.Name, "")
+
+	result.Attempts = attempts
+	result.EndTime = time.Now()
+
+	if err != nil {
 		j.healthy = false
 		j.lastErr = err
 
-		j.Logger().Printf("ERROR: Backup failed: %s", err.Error())
+		j.Logger().Printf("ERROR: %s failed: %s", jobTypeTitle(jobType), err.Error())
 
 		result.Success = false
+		result.Status = JobStatusFailure
 		result.LastError = err
+
+		switch {
+		case errors.Is(err, ErrStopped):
+			result.Status = JobStatusCancelled
+			result.Message = stoppedMessage
+		case errors.Is(err, ErrWindowClosed), errors.Is(err, ErrCancelled):
+			result.Status = JobStatusCancelled
+		case errors.Is(err, ErrTimeout):
+			result.Status = JobStatusTimeout
+		}
 	}
 
-	snapshots, err := j.NewRestic().ReadSnapshots()
+	snapshots, err := j.NewRestic().ReadSnapshots(ctx)
 	if err != nil {
 		// Set the last error on the result only if an actual backup error doesn't already exist
 		// An error reading snapshots is less severe than a failure to backup.
@@ -287,37 +752,187 @@ func (j Job) Run() {
 			result.LastError = err
 		}
 	} else {
+		snapshots = j.FilterSnapshots(snapshots)
+
 		Metrics.SnapshotCurrentCount.WithLabelValues(j.Name).Set(float64(len(snapshots)))
+		recordSnapshots(j.Name, snapshots)
 
 		if len(snapshots) > 0 {
 			latestSnapshot := snapshots[len(snapshots)-1]
 			Metrics.SnapshotLatestTime.WithLabelValues(j.Name).Set(float64(latestSnapshot.Time.Unix()))
+
+			// The latest snapshot was created by this run if it was taken after the run started
+			if jobType == JobTypeBackup && result.Success && !latestSnapshot.Time.Before(result.StartTime) {
+				result.SnapshotID = latestSnapshot.ID
+			}
 		}
 	}
 
-	if result.Success {
-		Metrics.JobFailureCount.WithLabelValues(j.Name).Set(0.0)
-	} else {
-		Metrics.JobFailureCount.WithLabelValues(j.Name).Inc()
+	switch {
+	case result.Success:
+		Metrics.JobOperationFailureCount.WithLabelValues(j.Name, jobType).Set(0.0)
+
+		if jobType == JobTypeBackup {
+			Metrics.JobFailureCount.WithLabelValues(j.Name).Set(0.0)
+		}
+	case !result.stoppedWithScheduler():
+		Metrics.JobOperationFailureCount.WithLabelValues(j.Name, jobType).Inc()
+
+		if jobType == JobTypeBackup {
+			Metrics.JobFailureCount.WithLabelValues(j.Name).Inc()
+		}
 	}
 
 	JobComplete(result)
+
+	return result
+}
+
+// jobTypeTitle returns the job type capitalized for the start of a log message.
+func jobTypeTitle(jobType string) string {
+	if jobType == "" {
+		return jobType
+	}
+
+	return strings.ToUpper(jobType[:1]) + jobType[1:]
+}
+
+// cancelledResult returns the result of a run that was cancelled with the provided cause before it
+// started, such as while it was queued or deferred until the job's window.
+func (j Job) cancelledResult(info RunInfo, cause error) JobResult {
+	now := time.Now()
+
+	return JobResult{
+		JobName:    j.Name,
+		JobType:    cmp.Or(info.JobType, JobTypeBackup),
+		Success:    false,
+		Status:     JobStatusCancelled,
+		LastError:  cause,
+		Message:    "cancelled before it started",
+		QueueWait:  info.QueueWait,
+		Attempts:   0,
+		Trigger:    info.Trigger,
+		StartTime:  now,
+		EndTime:    now,
+		SnapshotID: "",
+		Tasks:      nil,
+		RunID:      info.RunID,
+	}
+}
+
+// RecordSkipped records a result for a scheduled run of the provided job type that was skipped
+// for the provided reason. Runs skipped because a job they depend on failed are not successful.
+func (j Job) RecordSkipped(jobType, reason string) {
+	j.Logger().Printf("Skipping %s run: %s", jobType, reason)
+
+	Metrics.JobSkippedCount.WithLabelValues(j.Name, reason).Inc()
+
+	now := time.Now()
+
+	JobComplete(JobResult{
+		JobName:    j.Name,
+		JobType:    jobType,
+		Success:    reason != SkipReasonUpstreamFailure,
+		Status:     JobStatusSkipped,
+		LastError:  nil,
+		Message:    "skipped: " + reason,
+		QueueWait:  0,
+		Attempts:   0,
+		Trigger:    TriggerSchedule,
+		StartTime:  now,
+		EndTime:    now,
+		SnapshotID: "",
+		Tasks:      nil,
+		RunID:      "",
+	})
 }
 
 // RefreshMetrics updates the metrics for this job by reading the current snapshots from restic.
-func (j Job) RefreshMetrics() {
-	snapshots, err := j.NewRestic().ReadSnapshots()
+// It returns the time of the job's latest snapshot, or the zero time if there is none or reading
+// failed.
+func (j Job) RefreshMetrics() time.Time {
+	snapshots, err := j.NewRestic().ReadSnapshots(context.Background())
 	if err != nil {
 		j.Logger().Printf("ERROR: Failed to read snapshots while refreshing metrics: %s", err.Error())
-		return
+		return time.Time{}
 	}
 
+	snapshots = j.FilterSnapshots(snapshots)
+
 	Metrics.SnapshotCurrentCount.WithLabelValues(j.Name).Set(float64(len(snapshots)))
+	recordSnapshots(j.Name, snapshots)
 
-	if len(snapshots) > 0 {
-		latestSnapshot := snapshots[len(snapshots)-1]
-		Metrics.SnapshotLatestTime.WithLabelValues(j.Name).Set(float64(latestSnapshot.Time.Unix()))
+	if len(snapshots) == 0 {
+		return time.Time{}
 	}
+
+	latestSnapshot := snapshots[len(snapshots)-1]
+	Metrics.SnapshotLatestTime.WithLabelValues(j.Name).Set(float64(latestSnapshot.Time.Unix()))
+
+	return latestSnapshot.Time
+}
+
+// MissedRun returns true if the job was scheduled to run at least once between the latest
+// snapshot and now. If there is no latest snapshot, a missed run can't be detected.
+func (j Job) MissedRun(latestSnapshot, now time.Time) bool {
+	if latestSnapshot.IsZero() {
+		return false
+	}
+
+	spec, err := j.CronSchedule()
+	if err != nil {
+		return false
+	}
+
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return false
+	}
+
+	return !schedule.Next(latestSnapshot).After(now)
+}
+
+// FilterSnapshots returns the snapshots created by this job's backups, so that other jobs sharing
+// the repository don't affect its metrics, health or catch-up. Snapshots must have exactly the job's
+// backup paths and all of its backup tags. The host is only matched if the backup options set one.
+func (j Job) FilterSnapshots(snapshots []Snapshot) []Snapshot {
+	paths := Set{}
+
+	for _, path := range j.BackupPaths() {
+		if absPath, err := filepath.Abs(path); err == nil {
+			path = absPath
+		}
+
+		paths[filepath.Clean(path)] = true
+	}
+
+	opts := BackupOpts{} //nolint:exhaustruct
+	if j.Backup.BackupOpts != nil {
+		opts = *j.Backup.BackupOpts
+	}
+
+	return slices.DeleteFunc(slices.Clone(snapshots), func(snapshot Snapshot) bool {
+		if opts.Host != "" && snapshot.Hostname != opts.Host {
+			return true
+		}
+
+		for _, tag := range opts.Tags {
+			if !slices.Contains(snapshot.Tags, tag) {
+				return true
+			}
+		}
+
+		if len(paths) == 0 {
+			return false
+		}
+
+		snapshotPaths := Set{}
+		for _, path := range snapshot.Paths {
+			snapshotPaths[filepath.Clean(path)] = true
+		}
+
+		return !maps.Equal(paths, snapshotPaths)
+	})
 }
 
 // NewRestic returns a configured Restic command for this job configuration.
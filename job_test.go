@@ -1,8 +1,10 @@
 package main_test
 
 import (
+	"context"
 	"errors"
 	"testing"
+	"time"
 
 	main "git.iamthefij.com/iamthefij/restic-scheduler"
 )
@@ -128,6 +130,397 @@ func TestJobValidation(t *testing.T) {
 			},
 			expectedErr: main.ErrInvalidConfigValue,
 		},
+		{
+			name: "Valid hashed schedule",
+			job: main.Job{
+				Name:     "Valid job",
+				Schedule: "H H(0-5) * * *",
+				Jitter:   "10m",
+				Config:   ValidResticConfig(),
+				Tasks:    []main.JobTask{},
+				Backup:   main.BackupFilesTask{Paths: []string{"/test"}}, //nolint:exhaustruct
+				Forget:   nil,
+				MySQL:    []main.JobTaskMySQL{},
+				Postgres: []main.JobTaskPostgres{},
+				Sqlite:   []main.JobTaskSqlite{},
+			},
+			expectedErr: nil,
+		},
+		{
+			name: "Invalid hashed schedule",
+			job: main.Job{
+				Name:     "Test job",
+				Schedule: "H(5-1) * * * *",
+				Config:   ValidResticConfig(),
+				Tasks:    []main.JobTask{},
+				Backup:   main.BackupFilesTask{Paths: []string{"/test"}}, //nolint:exhaustruct
+				Forget:   nil,
+				MySQL:    []main.JobTaskMySQL{},
+				Postgres: []main.JobTaskPostgres{},
+				Sqlite:   []main.JobTaskSqlite{},
+			},
+			expectedErr: main.ErrInvalidConfigValue,
+		},
+		{
+			name: "Invalid jitter",
+			job: main.Job{
+				Name:     "Test job",
+				Schedule: "@daily",
+				Jitter:   "a bit",
+				Config:   ValidResticConfig(),
+				Tasks:    []main.JobTask{},
+				Backup:   main.BackupFilesTask{Paths: []string{"/test"}}, //nolint:exhaustruct
+				Forget:   nil,
+				MySQL:    []main.JobTaskMySQL{},
+				Postgres: []main.JobTaskPostgres{},
+				Sqlite:   []main.JobTaskSqlite{},
+			},
+			expectedErr: main.ErrInvalidConfigValue,
+		},
+		{
+			name: "Invalid timezone",
+			job: main.Job{
+				Name:     "Test job",
+				Schedule: "@daily",
+				Timezone: "Mars/Olympus_Mons",
+				Config:   ValidResticConfig(),
+				Tasks:    []main.JobTask{},
+				Backup:   main.BackupFilesTask{Paths: []string{"/test"}}, //nolint:exhaustruct
+				Forget:   nil,
+				MySQL:    []main.JobTaskMySQL{},
+				Postgres: []main.JobTaskPostgres{},
+				Sqlite:   []main.JobTaskSqlite{},
+			},
+			expectedErr: main.ErrInvalidConfigValue,
+		},
+		{
+			name: "Timezone and schedule prefix",
+			job: main.Job{
+				Name:     "Test job",
+				Schedule: "CRON_TZ=UTC 0 2 * * *",
+				Timezone: "Europe/Berlin",
+				Config:   ValidResticConfig(),
+				Tasks:    []main.JobTask{},
+				Backup:   main.BackupFilesTask{Paths: []string{"/test"}}, //nolint:exhaustruct
+				Forget:   nil,
+				MySQL:    []main.JobTaskMySQL{},
+				Postgres: []main.JobTaskPostgres{},
+				Sqlite:   []main.JobTaskSqlite{},
+			},
+			expectedErr: main.ErrMutuallyExclusive,
+		},
+		{
+			name: "Invalid window policy",
+			job: main.Job{
+				Name:           "Test job",
+				Schedule:       "@daily",
+				AllowedWindows: []main.TimeWindow{{Days: nil, Start: "22:00", End: "06:00"}},
+				WindowPolicy:   "wait",
+				Config:         ValidResticConfig(),
+				Tasks:          []main.JobTask{},
+				Backup:         main.BackupFilesTask{Paths: []string{"/test"}}, //nolint:exhaustruct
+				Forget:         nil,
+				MySQL:          []main.JobTaskMySQL{},
+				Postgres:       []main.JobTaskPostgres{},
+				Sqlite:         []main.JobTaskSqlite{},
+			},
+			expectedErr: main.ErrInvalidConfigValue,
+		},
+		{
+			name: "Invalid blackout",
+			job: main.Job{
+				Name:      "Test job",
+				Schedule:  "@daily",
+				Blackouts: []main.TimeWindow{{Days: []string{"someday"}, Start: "09:00", End: "17:00"}},
+				Config:    ValidResticConfig(),
+				Tasks:     []main.JobTask{},
+				Backup:    main.BackupFilesTask{Paths: []string{"/test"}}, //nolint:exhaustruct
+				Forget:    nil,
+				MySQL:     []main.JobTaskMySQL{},
+				Postgres:  []main.JobTaskPostgres{},
+				Sqlite:    []main.JobTaskSqlite{},
+			},
+			expectedErr: main.ErrInvalidConfigValue,
+		},
+		{
+			name: "Valid dependent job",
+			job: main.Job{
+				Name:      "Test job",
+				DependsOn: []string{"Other job"},
+				Config:    ValidResticConfig(),
+				Tasks:     []main.JobTask{},
+				Backup:    main.BackupFilesTask{Paths: []string{"/test"}}, //nolint:exhaustruct
+				Forget:    nil,
+				MySQL:     []main.JobTaskMySQL{},
+				Postgres:  []main.JobTaskPostgres{},
+				Sqlite:    []main.JobTaskSqlite{},
+			},
+			expectedErr: nil,
+		},
+		{
+			name: "Schedule and depends_on",
+			job: main.Job{
+				Name:      "Test job",
+				Schedule:  "@daily",
+				DependsOn: []string{"Other job"},
+				Config:    ValidResticConfig(),
+				Tasks:     []main.JobTask{},
+				Backup:    main.BackupFilesTask{Paths: []string{"/test"}}, //nolint:exhaustruct
+				Forget:    nil,
+				MySQL:     []main.JobTaskMySQL{},
+				Postgres:  []main.JobTaskPostgres{},
+				Sqlite:    []main.JobTaskSqlite{},
+			},
+			expectedErr: main.ErrMutuallyExclusive,
+		},
+		{
+			name: "Missing schedule",
+			job: main.Job{
+				Name:     "Test job",
+				Config:   ValidResticConfig(),
+				Tasks:    []main.JobTask{},
+				Backup:   main.BackupFilesTask{Paths: []string{"/test"}}, //nolint:exhaustruct
+				Forget:   nil,
+				MySQL:    []main.JobTaskMySQL{},
+				Postgres: []main.JobTaskPostgres{},
+				Sqlite:   []main.JobTaskSqlite{},
+			},
+			expectedErr: main.ErrMissingField,
+		},
+		{
+			name: "Invalid on_upstream_failure",
+			job: main.Job{
+				Name:              "Test job",
+				DependsOn:         []string{"Other job"},
+				OnUpstreamFailure: "retry",
+				Config:            ValidResticConfig(),
+				Tasks:             []main.JobTask{},
+				Backup:            main.BackupFilesTask{Paths: []string{"/test"}}, //nolint:exhaustruct
+				Forget:            nil,
+				MySQL:             []main.JobTaskMySQL{},
+				Postgres:          []main.JobTaskPostgres{},
+				Sqlite:            []main.JobTaskSqlite{},
+			},
+			expectedErr: main.ErrInvalidConfigValue,
+		},
+		{
+			name: "Valid maintenance schedules",
+			job: main.Job{
+				Name:           "Test job",
+				Schedule:       "@hourly",
+				ForgetSchedule: "@weekly",
+				CheckSchedule:  "H H 1 * *",
+				Forget:         &main.ForgetOpts{KeepLast: 3},         //nolint:exhaustruct
+				Check:          &main.CheckOpts{ReadDataSubset: "5%"}, //nolint:exhaustruct
+				Config:         ValidResticConfig(),
+				Tasks:          []main.JobTask{},
+				Backup:         main.BackupFilesTask{Paths: []string{"/test"}}, //nolint:exhaustruct
+				MySQL:          []main.JobTaskMySQL{},
+				Postgres:       []main.JobTaskPostgres{},
+				Sqlite:         []main.JobTaskSqlite{},
+			},
+			expectedErr: nil,
+		},
+		{
+			name: "Forget schedule without forget",
+			job: main.Job{
+				Name:           "Test job",
+				Schedule:       "@hourly",
+				ForgetSchedule: "@weekly",
+				Forget:         nil,
+				Config:         ValidResticConfig(),
+				Tasks:          []main.JobTask{},
+				Backup:         main.BackupFilesTask{Paths: []string{"/test"}}, //nolint:exhaustruct
+				MySQL:          []main.JobTaskMySQL{},
+				Postgres:       []main.JobTaskPostgres{},
+				Sqlite:         []main.JobTaskSqlite{},
+			},
+			expectedErr: main.ErrMissingField,
+		},
+		{
+			name: "Invalid check schedule",
+			job: main.Job{
+				Name:          "Test job",
+				Schedule:      "@hourly",
+				CheckSchedule: "monthly",
+				Forget:        nil,
+				Config:        ValidResticConfig(),
+				Tasks:         []main.JobTask{},
+				Backup:        main.BackupFilesTask{Paths: []string{"/test"}}, //nolint:exhaustruct
+				MySQL:         []main.JobTaskMySQL{},
+				Postgres:      []main.JobTaskPostgres{},
+				Sqlite:        []main.JobTaskSqlite{},
+			},
+			expectedErr: main.ErrInvalidConfigValue,
+		},
+		{
+			name: "Valid verify restore",
+			job: main.Job{
+				Name:     "Test job",
+				Schedule: "@hourly",
+				VerifyRestore: &main.VerifyRestoreConfig{
+					Schedule: "@weekly",
+					Snapshot: "",
+					Include:  []string{"/test/data.db"},
+					Script:   "sqlite3 ./test/data.db 'PRAGMA integrity_check'",
+				},
+				Config:   ValidResticConfig(),
+				Tasks:    []main.JobTask{},
+				Backup:   main.BackupFilesTask{Paths: []string{"/test"}}, //nolint:exhaustruct
+				MySQL:    []main.JobTaskMySQL{},
+				Postgres: []main.JobTaskPostgres{},
+				Sqlite:   []main.JobTaskSqlite{},
+			},
+			expectedErr: nil,
+		},
+		{
+			name: "Verify restore missing schedule",
+			job: main.Job{
+				Name:          "Test job",
+				Schedule:      "@hourly",
+				VerifyRestore: &main.VerifyRestoreConfig{Schedule: "", Snapshot: "", Include: nil, Script: "true"},
+				Config:        ValidResticConfig(),
+				Tasks:         []main.JobTask{},
+				Backup:        main.BackupFilesTask{Paths: []string{"/test"}}, //nolint:exhaustruct
+				MySQL:         []main.JobTaskMySQL{},
+				Postgres:      []main.JobTaskPostgres{},
+				Sqlite:        []main.JobTaskSqlite{},
+			},
+			expectedErr: main.ErrMissingField,
+		},
+		{
+			name: "Verify restore invalid schedule",
+			job: main.Job{
+				Name:          "Test job",
+				Schedule:      "@hourly",
+				VerifyRestore: &main.VerifyRestoreConfig{Schedule: "weekly", Snapshot: "", Include: nil, Script: "true"},
+				Config:        ValidResticConfig(),
+				Tasks:         []main.JobTask{},
+				Backup:        main.BackupFilesTask{Paths: []string{"/test"}}, //nolint:exhaustruct
+				MySQL:         []main.JobTaskMySQL{},
+				Postgres:      []main.JobTaskPostgres{},
+				Sqlite:        []main.JobTaskSqlite{},
+			},
+			expectedErr: main.ErrInvalidConfigValue,
+		},
+		{
+			name: "Invalid overlap",
+			job: main.Job{
+				Name:     "Test job",
+				Schedule: "@daily",
+				Overlap:  "sometimes",
+				Config:   ValidResticConfig(),
+				Tasks:    []main.JobTask{},
+				Backup:   main.BackupFilesTask{Paths: []string{"/test"}}, //nolint:exhaustruct
+				Forget:   nil,
+				MySQL:    []main.JobTaskMySQL{},
+				Postgres: []main.JobTaskPostgres{},
+				Sqlite:   []main.JobTaskSqlite{},
+			},
+			expectedErr: main.ErrInvalidConfigValue,
+		},
+		{
+			name: "Invalid timeout",
+			job: main.Job{
+				Name:     "Test job",
+				Schedule: "@daily",
+				Timeout:  "forever",
+				Config:   ValidResticConfig(),
+				Tasks:    []main.JobTask{},
+				Backup:   main.BackupFilesTask{Paths: []string{"/test"}}, //nolint:exhaustruct
+				Forget:   nil,
+				MySQL:    []main.JobTaskMySQL{},
+				Postgres: []main.JobTaskPostgres{},
+				Sqlite:   []main.JobTaskSqlite{},
+			},
+			expectedErr: main.ErrInvalidConfigValue,
+		},
+		{
+			name: "Zero timeout",
+			job: main.Job{
+				Name:     "Test job",
+				Schedule: "@daily",
+				Timeout:  "0s",
+				Config:   ValidResticConfig(),
+				Tasks:    []main.JobTask{},
+				Backup:   main.BackupFilesTask{Paths: []string{"/test"}}, //nolint:exhaustruct
+				Forget:   nil,
+				MySQL:    []main.JobTaskMySQL{},
+				Postgres: []main.JobTaskPostgres{},
+				Sqlite:   []main.JobTaskSqlite{},
+			},
+			expectedErr: main.ErrInvalidConfigValue,
+		},
+		{
+			name: "Invalid max snapshot age",
+			job: main.Job{
+				Name:           "Test job",
+				Schedule:       "@daily",
+				MaxSnapshotAge: "a week",
+				Config:         ValidResticConfig(),
+				Tasks:          []main.JobTask{},
+				Backup:         main.BackupFilesTask{Paths: []string{"/test"}}, //nolint:exhaustruct
+				Forget:         nil,
+				MySQL:          []main.JobTaskMySQL{},
+				Postgres:       []main.JobTaskPostgres{},
+				Sqlite:         []main.JobTaskSqlite{},
+			},
+			expectedErr: main.ErrInvalidConfigValue,
+		},
+		{
+			name: "Invalid task timeout",
+			job: main.Job{
+				Name:     "Test job",
+				Schedule: "@daily",
+				Config:   ValidResticConfig(),
+				Tasks: []main.JobTask{
+					{Name: "slow", Timeout: "forever"}, //nolint:exhaustruct
+				},
+				Backup:   main.BackupFilesTask{Paths: []string{"/test"}}, //nolint:exhaustruct
+				Forget:   nil,
+				MySQL:    []main.JobTaskMySQL{},
+				Postgres: []main.JobTaskPostgres{},
+				Sqlite:   []main.JobTaskSqlite{},
+			},
+			expectedErr: main.ErrInvalidConfigValue,
+		},
+		{
+			name: "Negative task timeout",
+			job: main.Job{
+				Name:     "Test job",
+				Schedule: "@daily",
+				Config:   ValidResticConfig(),
+				Tasks: []main.JobTask{
+					{Name: "slow", Timeout: "-1m"}, //nolint:exhaustruct
+				},
+				Backup:   main.BackupFilesTask{Paths: []string{"/test"}}, //nolint:exhaustruct
+				Forget:   nil,
+				MySQL:    []main.JobTaskMySQL{},
+				Postgres: []main.JobTaskPostgres{},
+				Sqlite:   []main.JobTaskSqlite{},
+			},
+			expectedErr: main.ErrInvalidConfigValue,
+		},
+		{
+			name: "Invalid nested postgres task timeout",
+			job: main.Job{
+				Name:     "Test job",
+				Schedule: "@daily",
+				Config:   ValidResticConfig(),
+				Tasks: []main.JobTask{
+					{ //nolint:exhaustruct
+						Name:     "dump",
+						Postgres: []main.JobTaskPostgres{{Name: "db", Timeout: "forever"}}, //nolint:exhaustruct
+					},
+				},
+				Backup:   main.BackupFilesTask{Paths: []string{"/test"}}, //nolint:exhaustruct
+				Forget:   nil,
+				MySQL:    []main.JobTaskMySQL{},
+				Postgres: []main.JobTaskPostgres{},
+				Sqlite:   []main.JobTaskSqlite{},
+			},
+			expectedErr: main.ErrInvalidConfigValue,
+		},
 		{
 			name: "Invalid config",
 			job: main.Job{
@@ -315,3 +708,116 @@ func TestConfigValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestJobMissedRun(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, time.March, 10, 12, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name      string
+		schedule  string
+		snapshots []main.Snapshot
+		expected  bool
+	}{
+		{
+			name:      "No snapshot",
+			schedule:  "0 2 * * *",
+			snapshots: nil,
+			expected:  false,
+		},
+		{
+			name:      "Snapshot since last scheduled run",
+			schedule:  "0 2 * * *",
+			snapshots: []main.Snapshot{jobSnapshot(time.Date(2026, time.March, 10, 2, 0, 5, 0, time.UTC))},
+			expected:  false,
+		},
+		{
+			name:      "Missed daily run",
+			schedule:  "0 2 * * *",
+			snapshots: []main.Snapshot{jobSnapshot(time.Date(2026, time.March, 9, 2, 0, 5, 0, time.UTC))},
+			expected:  true,
+		},
+		{
+			name:      "Weekly run not yet due",
+			schedule:  "0 2 * * 0",
+			snapshots: []main.Snapshot{jobSnapshot(time.Date(2026, time.March, 8, 2, 0, 5, 0, time.UTC))},
+			expected:  false,
+		},
+		{
+			name:      "Missed weekly run",
+			schedule:  "0 2 * * 0",
+			snapshots: []main.Snapshot{jobSnapshot(time.Date(2026, time.February, 22, 2, 0, 5, 0, time.UTC))},
+			expected:  true,
+		},
+		{
+			name:     "Missed daily run in shared repository",
+			schedule: "0 2 * * *",
+			snapshots: []main.Snapshot{
+				jobSnapshot(time.Date(2026, time.March, 9, 2, 0, 5, 0, time.UTC)),
+				{Time: time.Date(2026, time.March, 10, 2, 0, 5, 0, time.UTC), Paths: []string{"/other"}},                           //nolint:exhaustruct
+				{Time: time.Date(2026, time.March, 10, 3, 0, 5, 0, time.UTC), Paths: []string{"/data"}, Hostname: "other"},         //nolint:exhaustruct
+				{Time: time.Date(2026, time.March, 10, 4, 0, 5, 0, time.UTC), Paths: []string{"/data"}, Tags: []string{"nightly"}}, //nolint:exhaustruct
+			},
+			expected: true,
+		},
+	}
+
+	for _, c := range cases {
+		testCase := c
+
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			job := main.Job{ //nolint:exhaustruct
+				Name:     "Test job",
+				Schedule: testCase.schedule,
+				Timezone: "UTC",
+				Backup: main.BackupFilesTask{ //nolint:exhaustruct
+					Paths:      []string{"/data"},
+					BackupOpts: &main.BackupOpts{Host: "host", Tags: []string{"hourly"}}, //nolint:exhaustruct
+				},
+			}
+
+			latestSnapshot := time.Time{}
+			if snapshots := job.FilterSnapshots(testCase.snapshots); len(snapshots) > 0 {
+				latestSnapshot = snapshots[len(snapshots)-1].Time
+			}
+
+			AssertEqual(t, "missed run", testCase.expected, job.MissedRun(latestSnapshot, now))
+		})
+	}
+}
+
+// jobSnapshot returns a snapshot taken at the provided time by the job in TestJobMissedRun.
+func jobSnapshot(taken time.Time) main.Snapshot {
+	return main.Snapshot{ //nolint:exhaustruct
+		Time:     taken,
+		Hostname: "host",
+		Paths:    []string{"/data"},
+		Tags:     []string{"hourly"},
+	}
+}
+
+// TestJobTimeoutIncludesRetries replaces restic with a script that hangs, so it can't run in parallel.
+func TestJobTimeoutIncludesRetries(t *testing.T) {
+	fakeRestic(t, "case \"$*\" in\n*snapshots*) echo '[]' ;;\n*) sleep 10 ;;\nesac\n")
+
+	job := main.Job{ //nolint:exhaustruct
+		Name:     uniqueJobName("TestJobTimeoutIncludesRetries"),
+		Schedule: "@daily",
+		Timeout:  "500ms",
+		Config:   ValidResticConfig(),
+		Retry:    &main.RetryConfig{MaxAttempts: 3, InitialDelay: "10ms"}, //nolint:exhaustruct
+	}
+
+	result := job.RunWithInfo(context.Background(), main.RunInfo{JobType: main.JobTypeCheck}) //nolint:exhaustruct
+
+	// The timeout limits the whole run rather than each attempt
+	AssertEqual(t, "unexpected status", main.JobStatusTimeout, result.Status)
+	AssertEqual(t, "unexpected attempts", 1, result.Attempts)
+
+	if duration := result.EndTime.Sub(result.StartTime); duration > 2*time.Second {
+		t.Errorf("expected run to stop at its timeout but it took %s", duration)
+	}
+}
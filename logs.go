@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultLogLines is how many buffered lines the logs endpoint returns when no count is provided.
+	defaultLogLines = 100
+	// logSubscriberBuffer is how many lines may be waiting to be sent to a follower before new
+	// lines are dropped for it.
+	logSubscriberBuffer = 256
+	// logKeepAliveInterval is how often a comment is sent to idle followers to keep the connection open.
+	logKeepAliveInterval = 15 * time.Second
+)
+
+var (
+	// LogBufferLines is how many of the most recent log lines are kept for each job.
+	LogBufferLines = 1000
+
+	logBuffers = sync.Map{}
+)
+
+// LogBuffer keeps the most recent lines logged by a job and sends new lines to followers.
+type LogBuffer struct {
+	mu          sync.Mutex
+	lines       []string
+	subscribers map[chan string]struct{}
+}
+
+// GetLogBuffer gets the log buffer for a job by name or creates one if it doesn't exist yet.
+func GetLogBuffer(name string) *LogBuffer {
+	buffer, _ := logBuffers.LoadOrStore(name, &LogBuffer{
+		mu:          sync.Mutex{},
+		lines:       []string{},
+		subscribers: map[chan string]struct{}{},
+	})
+
+	return buffer.(*LogBuffer)
+}
+
+// Write stores each written line, dropping the oldest once there are more than LogBufferLines,
+// and sends them to followers. Followers that aren't keeping up miss lines rather than blocking.
+func (b *LogBuffer) Write(content []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for line := range strings.SplitSeq(strings.TrimSuffix(string(content), "\n"), "\n") {
+		b.lines = append(b.lines, line)
+
+		for subscriber := range b.subscribers {
+			select {
+			case subscriber <- line:
+			default:
+			}
+		}
+	}
+
+	if overflow := len(b.lines) - LogBufferLines; overflow > 0 {
+		b.lines = slices.Delete(b.lines, 0, overflow)
+	}
+
+	return len(content), nil
+}
+
+// Lines returns up to count of the most recent lines, oldest first.
+func (b *LogBuffer) Lines(count int) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.linesLocked(count)
+}
+
+// Follow returns up to count of the most recent lines and a channel that receives every line
+// written afterwards. Call the returned function to stop following.
+func (b *LogBuffer) Follow(count int) ([]string, <-chan string, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	subscriber := make(chan string, logSubscriberBuffer)
+	b.subscribers[subscriber] = struct{}{}
+
+	unfollow := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		delete(b.subscribers, subscriber)
+	}
+
+	return b.linesLocked(count), subscriber, unfollow
+}
+
+// linesLocked returns a copy of up to count of the most recent lines. The caller must hold b.mu.
+func (b *LogBuffer) linesLocked(count int) []string {
+	start := max(len(b.lines)-count, 0)
+
+	return slices.Clone(b.lines[start:])
+}
+
+// LogsHandleFunc serves the most recent log lines of the job named in the path. The `lines` query
+// parameter sets how many are returned. With `follow=true`, the lines are sent as Server-Sent
+// Events followed by new lines as they are logged, until the client disconnects.
+func LogsHandleFunc(writer http.ResponseWriter, request *http.Request, sched *Scheduler) {
+	name := request.PathValue("name")
+	if !slices.Contains(sched.ActiveJobNames(), name) {
+		http.Error(writer, fmt.Sprintf("%s: %s", ErrJobNotFound, name), http.StatusNotFound)
+		return
+	}
+
+	query := request.URL.Query()
+
+	count := defaultLogLines
+
+	if rawCount := query.Get("lines"); rawCount != "" {
+		var err error
+		if count, err = strconv.Atoi(rawCount); err != nil || count < 0 {
+			http.Error(writer, "lines must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+	}
+
+	buffer := GetLogBuffer(name)
+
+	if follow, _ := strconv.ParseBool(query.Get("follow")); !follow {
+		writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+		for _, line := range buffer.Lines(count) {
+			fmt.Fprintln(writer, line)
+		}
+
+		return
+	}
+
+	lines, followed, unfollow := buffer.Follow(count)
+	defer unfollow()
+
+	controller := http.NewResponseController(writer)
+
+	writer.Header().Set("Content-Type", "text/event-stream")
+	writer.Header().Set("Cache-Control", "no-cache")
+
+	for _, line := range lines {
+		fmt.Fprintf(writer, "data: %s\n\n", line)
+	}
+
+	if err := controller.Flush(); err != nil {
+		return
+	}
+
+	keepAlive := time.NewTicker(logKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-request.Context().Done():
+			return
+		case line := <-followed:
+			fmt.Fprintf(writer, "data: %s\n\n", line)
+		case <-keepAlive.C:
+			fmt.Fprint(writer, ": keep-alive\n\n")
+		}
+
+		if err := controller.Flush(); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package main_test
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	main "git.iamthefij.com/iamthefij/restic-scheduler"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogBuffer(t *testing.T) {
+	t.Parallel()
+
+	buffer := main.GetLogBuffer(t.Name())
+
+	for i := range main.LogBufferLines + 5 {
+		fmt.Fprintf(buffer, "line %d\n", i)
+	}
+
+	lines := buffer.Lines(main.LogBufferLines + 5)
+	AssertEqual(t, "unexpected number of buffered lines", main.LogBufferLines, len(lines))
+	AssertEqual(t, "unexpected oldest line", "line 5", lines[0])
+
+	AssertEqual(t, "unexpected last lines", []string{"line 1003", "line 1004"}, buffer.Lines(2))
+
+	backlog, followed, unfollow := buffer.Follow(1)
+	defer unfollow()
+
+	AssertEqual(t, "unexpected backlog", []string{"line 1004"}, backlog)
+
+	fmt.Fprint(buffer, "first\nsecond\n")
+	AssertEqual(t, "unexpected followed line", "first", <-followed)
+	AssertEqual(t, "unexpected followed line", "second", <-followed)
+}
+
+func TestGetLoggerWritesToLogBuffer(t *testing.T) {
+	t.Parallel()
+
+	logger := main.GetLogger(t.Name())
+	main.GetChildLogger(logger, "task").Print("from child")
+
+	lines := main.GetLogBuffer(t.Name()).Lines(1)
+	AssertEqualFail(t, "unexpected number of lines", 1, len(lines))
+	assert.Contains(t, lines[0], t.Name()+":task:from child")
+}
+
+func TestLogsHandleFunc(t *testing.T) {
+	t.Parallel()
+
+	jobName := "TestLogsJob"
+	sched := main.NewScheduler()
+
+	err := sched.Start([]main.Job{
+		{Name: jobName, Schedule: "@daily"}, //nolint:exhaustruct
+	})
+	AssertEqualFail(t, "unexpected error starting scheduler", nil, err)
+
+	defer sched.StopGraceful(0)
+
+	logger := main.GetLogger(jobName)
+	logger.Print("first")
+	logger.Print("second")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /jobs/{name}/logs", func(w http.ResponseWriter, r *http.Request) {
+		main.LogsHandleFunc(w, r, sched)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	// Unknown jobs are not found
+	resp, err := http.Get(server.URL + "/jobs/missing/logs")
+	AssertEqualFail(t, "unexpected error requesting logs", nil, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// Without follow, the last lines are returned as text
+	resp, err = http.Get(server.URL + "/jobs/" + jobName + "/logs?lines=1")
+	AssertEqualFail(t, "unexpected error requesting logs", nil, err)
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	AssertEqualFail(t, "unexpected error reading logs", nil, err)
+	assert.Equal(t, 1, strings.Count(string(body), "\n"))
+	assert.Contains(t, string(body), jobName+":second")
+
+	// With follow, buffered lines are sent followed by new lines as events
+	resp, err = http.Get(server.URL + "/jobs/" + jobName + "/logs?lines=1&follow=true")
+	AssertEqualFail(t, "unexpected error requesting logs", nil, err)
+
+	defer resp.Body.Close()
+
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	reader := bufio.NewReader(resp.Body)
+	readEvent := func() string {
+		line, err := reader.ReadString('\n')
+		AssertEqualFail(t, "unexpected error reading event", nil, err)
+
+		_, err = reader.ReadString('\n')
+		AssertEqualFail(t, "unexpected error reading event separator", nil, err)
+
+		return line
+	}
+
+	assert.Contains(t, readEvent(), jobName+":second")
+
+	logger.Print("third")
+	assert.Contains(t, readEvent(), jobName+":third")
+}
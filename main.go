@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"flag"
 	"fmt"
@@ -10,6 +11,11 @@ import (
 	"os/signal"
 	"strings"
 	"syscall"
+	"text/tabwriter"
+	"time"
+
+	// Embed the time zone database so job timezones work on hosts without one installed
+	_ "time/tzdata"
 )
 
 var (
@@ -18,25 +24,40 @@ var (
 	ErrJobNotFound = errors.New("jobs not found")
 )
 
-func ReadJobs(paths []string) ([]Job, error) {
-	allJobs := []Job{}
+// ReadConfig reads all provided config files and merges them into a single Config.
+func ReadConfig(paths []string) (*Config, error) {
+	allConfig := &Config{DefaultConfig: nil, MaxConcurrentJobs: 0, HTTPAuth: nil, Notify: []NotifyConfig{}, Jobs: []Job{}}
 
 	for _, path := range paths {
-		jobs, err := ParseConfig(path)
+		config, err := ParseConfig(path)
 		if err != nil {
 			return nil, err
 		}
 
-		if jobs != nil {
-			allJobs = append(allJobs, jobs...)
+		if err := allConfig.Merge(*config); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
 		}
 	}
 
-	if len(allJobs) == 0 {
-		return allJobs, fmt.Errorf("no jobs found in provided configuration: %w", ErrJobNotFound)
+	if len(allConfig.Jobs) == 0 {
+		return allConfig, fmt.Errorf("no jobs found in provided configuration: %w", ErrJobNotFound)
 	}
 
-	return allJobs, nil
+	if err := ValidateDependencies(allConfig.Jobs); err != nil {
+		return nil, fmt.Errorf("invalid job dependencies: %w", err)
+	}
+
+	return allConfig, nil
+}
+
+// ReadJobs reads all provided config files and returns the jobs they define.
+func ReadJobs(paths []string) ([]Job, error) {
+	config, err := ReadConfig(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	return config.Jobs, nil
 }
 
 // FilterJobs filters a list of jobs by a list of names.
@@ -77,8 +98,14 @@ func runBackupJobs(jobs []Job, names string) error {
 
 	jobs, filterJobErr := FilterJobs(jobs, namesSlice)
 	for _, job := range jobs {
-		if err := job.RunBackup(); err != nil {
-			return err
+		result := job.RunWithInfo(context.Background(), RunInfo{
+			QueueWait: 0,
+			Trigger:   TriggerManual,
+			JobType:   JobTypeBackup,
+			RunID:     "",
+		})
+		if !result.Success {
+			return result.LastError
 		}
 	}
 
@@ -98,7 +125,7 @@ func runRestoreJobs(jobs []Job, names string, snapshot string) error {
 
 	jobs, filterJobErr := FilterJobs(jobs, namesSlice)
 	for _, job := range jobs {
-		if err := job.RunRestore(snapshot); err != nil {
+		if err := job.RunRestore(context.Background(), snapshot); err != nil {
 			return err
 		}
 	}
@@ -119,7 +146,7 @@ func runUnlockJobs(jobs []Job, names string) error {
 
 	jobs, filterJobErr := FilterJobs(jobs, namesSlice)
 	for _, job := range jobs {
-		if err := job.NewRestic().Unlock(UnlockOpts{RemoveAll: true}); err != nil {
+		if err := job.NewRestic().Unlock(context.Background(), UnlockOpts{RemoveAll: true}); err != nil {
 			return err
 		}
 	}
@@ -136,6 +163,14 @@ type Flags struct {
 	once               bool
 	healthCheckAddr    string
 	metricsPushGateway string
+	stopTimeout        time.Duration
+	stateDir           string
+	history            string
+	historyLimit       int
+	historyKeep        int
+	tlsCert            string
+	tlsKey             string
+	tlsClientCA        string
 }
 
 func readFlags() Flags {
@@ -149,6 +184,16 @@ func readFlags() Flags {
 	flag.StringVar(&flags.metricsPushGateway, "push-gateway", "", "url of push gateway service for batch runs (optional)")
 	flag.StringVar(&JobBaseDir, "base-dir", JobBaseDir, "Base dir to create intermediate job files like SQL dumps.")
 	flag.StringVar(&flags.restoreSnapshot, "snapshot", "latest", "the snapshot to restore")
+	flag.DurationVar(&flags.stopTimeout, "stop-timeout", 0, "How long to wait for running jobs on SIGTERM before terminating them. 0 waits forever.")
+	flag.DurationVar(&KillGracePeriod, "kill-grace", KillGracePeriod, "How long terminated job processes have to exit after SIGTERM before they are killed.")
+	flag.IntVar(&LogBufferLines, "log-lines", LogBufferLines, "Number of recent log lines to keep in memory for each job.")
+	flag.StringVar(&flags.stateDir, "state-dir", "", "Dir to persist run history and paused jobs in. They are not kept across restarts if unset.")
+	flag.StringVar(&flags.history, "history", "", "Print run history for a job from -state-dir and exit. `all` will print all jobs.")
+	flag.IntVar(&flags.historyLimit, "history-limit", defaultHistoryLimit, "Number of runs to print with -history. 0 will print all.")
+	flag.IntVar(&flags.historyKeep, "history-keep", defaultHistoryMaxRecords, "Number of runs to keep in the run history in -state-dir. Older runs are removed. 0 keeps all.")
+	flag.StringVar(&flags.tlsCert, "tls-cert", "", "Certificate file to serve the HTTP API with TLS. Requires -tls-key.")
+	flag.StringVar(&flags.tlsKey, "tls-key", "", "Private key file for -tls-cert.")
+	flag.StringVar(&flags.tlsClientCA, "tls-client-ca", "", "CA certificate file used to require and verify client certificates. Requires -tls-cert.")
 	flag.Parse()
 
 	return flags
@@ -201,6 +246,54 @@ func printVersion() {
 	}
 }
 
+// printHistory prints up to limit of the most recent runs of the named job, or all jobs if the
+// name is `all`, newest first.
+func printHistory(store *HistoryStore, name string, limit int) error {
+	if name == "all" {
+		name = ""
+	}
+
+	records, err := store.Read(name, limit)
+	if err != nil {
+		return err
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0) //nolint:mnd
+
+	fmt.Fprintln(writer, "START\tJOB\tTYPE\tTRIGGER\tSTATUS\tDURATION\tSNAPSHOT\tERROR")
+
+	for _, record := range records {
+		fmt.Fprintf(
+			writer,
+			"%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			record.StartTime.Local().Format(time.DateTime),
+			record.JobName,
+			record.JobType,
+			record.Trigger,
+			record.Status,
+			record.Duration.Round(time.Second),
+			record.SnapshotID,
+			record.Error,
+		)
+	}
+
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("failed printing history: %w", err)
+	}
+
+	return nil
+}
+
+// refreshJobs refreshes the metrics of each job from its repository. Jobs with catch_up enabled
+// that missed a scheduled run since their latest snapshot have a run queued immediately.
+func refreshJobs(sched *Scheduler, jobs []Job) {
+	for _, job := range jobs {
+		log.Printf("Refreshing metrics for job %s", job.Name)
+
+		sched.CatchUp(job, job.RefreshMetrics())
+	}
+}
+
 func main() {
 	flags := readFlags()
 
@@ -210,6 +303,28 @@ func main() {
 		return
 	}
 
+	if flags.stateDir != "" {
+		store, err := NewHistoryStore(flags.stateDir, flags.historyKeep)
+		if err != nil {
+			log.Fatalf("Failed to open run history: %v", err)
+		}
+
+		History = store
+	}
+
+	// Print history if flag is provided
+	if flags.history != "" {
+		if History == nil {
+			log.Fatalf("Printing history requires -state-dir")
+		}
+
+		if err := printHistory(History, flags.history, flags.historyLimit); err != nil {
+			log.Fatal(err)
+		}
+
+		return
+	}
+
 	if _, err := exec.LookPath("restic"); err != nil {
 		log.Fatalf("Could not find restic in path. Make sure it's installed")
 	}
@@ -221,17 +336,35 @@ func main() {
 	// Capture the job file paths once here in main so reloads use the same input
 	jobPaths := flag.Args()
 
-	jobs, err := ReadJobs(jobPaths)
+	config, err := ReadConfig(jobPaths)
 	if err != nil {
 		log.Fatalf("Failed to read jobs from files: %v", err)
 	}
 
+	jobs := config.Jobs
+
+	SetNotifiers(config.Notify, jobs)
+
+	// Restore the last results from before a restart so health checks reflect them
+	if History != nil {
+		if err := History.Compact(); err != nil {
+			log.Printf("Failed to compact run history: %v", err)
+		}
+
+		if err := RestoreJobResults(History); err != nil {
+			log.Printf("Failed to restore last job results from history: %v", err)
+		}
+	}
+
 	if err := runSpecifiedJobs(jobs, flags.backup, flags.restore, flags.unlock, flags.restoreSnapshot); err != nil {
 		log.Fatal(err)
 	}
 
 	// Exit if only running once
 	if flags.once {
+		StopDigests()
+		WaitNotifications(notifyShutdownTimeout)
+
 		if err := maybePushMetrics(flags.metricsPushGateway); err != nil {
 			log.Fatal(err)
 		}
@@ -241,19 +374,41 @@ func main() {
 
 	// Create scheduler and start it with the initial job set.
 	sched := NewScheduler()
+	sched.SetMaxConcurrentJobs(config.MaxConcurrentJobs)
+
+	if flags.stateDir != "" {
+		if err := sched.LoadPauseState(flags.stateDir); err != nil {
+			log.Fatalf("Failed to load paused jobs: %v", err)
+		}
+	}
+
 	if err := sched.Start(jobs); err != nil {
 		log.Fatalf("failed to start scheduler: %v", err)
 	}
 
+	httpOptions := HTTPOptions{
+		TLSCertFile:     flags.tlsCert,
+		TLSKeyFile:      flags.tlsKey,
+		TLSClientCAFile: flags.tlsClientCA,
+		Auth:            NewAuthenticator(config.HTTPAuth),
+	}
+
+	if err := httpOptions.Validate(); err != nil {
+		log.Fatalf("Invalid HTTP API options: %v", err)
+	}
+
+	if config.HTTPAuth == nil {
+		log.Println("No http_auth block configured; endpoints that run, cancel, pause or resume jobs are disabled")
+	}
+
 	// Start HTTP handlers and provide the scheduler so /active can report live jobs.
 	go func() {
-		_ = RunHTTPHandlers(flags.healthCheckAddr, sched)
+		if err := RunHTTPHandlers(flags.healthCheckAddr, sched, httpOptions); err != nil {
+			log.Printf("ERROR: %v", err)
+		}
 	}()
 
-	for _, job := range jobs {
-		log.Printf("Refreshing metrics for job %s", job.Name)
-		job.RefreshMetrics()
-	}
+	refreshJobs(sched, jobs)
 
 	// Main owns signal handling and config reload.
 	sigCh := make(chan os.Signal, 1)
@@ -266,27 +421,29 @@ func main() {
 			// Reload config and apply via scheduler.ReplaceJobs
 			log.Println("Received SIGHUP; reloading configuration...")
 
-			newJobs, readErr := ReadJobs(jobPaths)
+			newConfig, readErr := ReadConfig(jobPaths)
 			if readErr != nil {
 				log.Printf("Failed to reload jobs: %v; keeping existing schedule", readErr)
 				continue
 			}
 
-			// Refresh metrics for the new job set before replacing to populate gauges.
-			for _, j := range newJobs {
-				log.Printf("Refreshing metrics for job %s", j.Name)
-				j.RefreshMetrics()
-			}
+			newJobs := newConfig.Jobs
 
 			if err := sched.ReplaceJobs(newJobs); err != nil {
 				log.Printf("Failed to apply reloaded jobs: %v; keeping previous schedule", err)
 				continue
 			}
 
+			sched.SetMaxConcurrentJobs(newConfig.MaxConcurrentJobs)
+			httpOptions.Auth.SetAuth(newConfig.HTTPAuth)
+			SetNotifiers(newConfig.Notify, newJobs)
+
+			// Refresh metrics for the new job set to populate gauges and catch up missed runs.
+			refreshJobs(sched, newJobs)
 			log.Println("Configuration reload successful")
 
 		case syscall.SIGINT:
-			// Immediate stop: do not wait for running jobs.
+			// Immediate stop: terminate running jobs rather than waiting for them.
 			log.Println("Received SIGINT; stopping immediately")
 			sched.StopNow()
 
@@ -294,7 +451,9 @@ func main() {
 		case syscall.SIGTERM, syscall.SIGQUIT:
 			// Graceful stop: wait for running jobs to finish.
 			log.Println("Received termination signal; stopping gracefully")
-			sched.StopGraceful()
+			sched.StopGraceful(flags.stopTimeout)
+			StopDigests()
+			WaitNotifications(notifyShutdownTimeout)
 
 			return
 		}
@@ -40,6 +40,40 @@ func TestReadJobs(t *testing.T) {
 	}
 }
 
+func TestReadConfig(t *testing.T) {
+	t.Parallel()
+
+	config, err := main.ReadConfig([]string{"./test/sample.hcl"})
+	if err != nil {
+		t.Errorf("Unexpected error reading config: %v", err)
+	}
+
+	AssertEqual(t, "unexpected max_concurrent_jobs", 2, config.MaxConcurrentJobs)
+}
+
+func TestConfigMerge(t *testing.T) {
+	t.Parallel()
+
+	config := main.Config{MaxConcurrentJobs: 0} //nolint:exhaustruct
+
+	err := config.Merge(main.Config{MaxConcurrentJobs: 2}) //nolint:exhaustruct
+	AssertEqualFail(t, "unexpected error merging config", nil, err)
+	AssertEqual(t, "unexpected max_concurrent_jobs", 2, config.MaxConcurrentJobs)
+
+	err = config.Merge(main.Config{MaxConcurrentJobs: 3}) //nolint:exhaustruct
+	if !errors.Is(err, main.ErrInvalidConfigValue) {
+		t.Errorf("expected conflicting values to fail with %v but found %v", main.ErrInvalidConfigValue, err)
+	}
+
+	err = config.Merge(main.Config{HTTPAuth: &main.HTTPAuth{}}) //nolint:exhaustruct
+	AssertEqualFail(t, "unexpected error merging http_auth", nil, err)
+
+	err = config.Merge(main.Config{HTTPAuth: &main.HTTPAuth{}}) //nolint:exhaustruct
+	if !errors.Is(err, main.ErrInvalidConfigValue) {
+		t.Errorf("expected http_auth defined twice to fail with %v but found %v", main.ErrInvalidConfigValue, err)
+	}
+}
+
 func TestRunJobs(t *testing.T) {
 	t.Parallel()
 
@@ -12,11 +12,19 @@ var Metrics = InitMetrics()
 
 // ResticMetrics contains Prometheus metrics for monitoring restic jobs and snapshots.
 type ResticMetrics struct {
-	JobStartTime         *prometheus.GaugeVec
-	JobFailureCount      *prometheus.GaugeVec
-	SnapshotCurrentCount *prometheus.GaugeVec
-	SnapshotLatestTime   *prometheus.GaugeVec
-	Registry             *prometheus.Registry
+	JobStartTime             *prometheus.GaugeVec
+	JobFailureCount          *prometheus.GaugeVec
+	JobQueueWait             *prometheus.GaugeVec
+	JobSkippedCount          *prometheus.CounterVec
+	JobRetryCount            *prometheus.CounterVec
+	JobDeferred              *prometheus.GaugeVec
+	JobPaused                *prometheus.GaugeVec
+	JobOperationStartTime    *prometheus.GaugeVec
+	JobOperationFailureCount *prometheus.GaugeVec
+	JobDeferredCount         *prometheus.CounterVec
+	SnapshotCurrentCount     *prometheus.GaugeVec
+	SnapshotLatestTime       *prometheus.GaugeVec
+	Registry                 *prometheus.Registry
 }
 
 // PushToGateway pushes the current metrics to a Prometheus Pushgateway.
@@ -57,6 +65,86 @@ func InitMetrics() *ResticMetrics {
 			},
 			labelNames,
 		),
+		JobQueueWait: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name:        "restic_job_queue_wait_seconds",
+				Help:        "seconds the last run of a job waited for its repository",
+				Namespace:   "",
+				Subsystem:   "",
+				ConstLabels: nil,
+			},
+			labelNames,
+		),
+		JobSkippedCount: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        "restic_job_skipped_total",
+				Help:        "number of scheduled job runs that were skipped",
+				Namespace:   "",
+				Subsystem:   "",
+				ConstLabels: nil,
+			},
+			[]string{"job", "reason"},
+		),
+		JobRetryCount: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        "restic_job_retry_total",
+				Help:        "number of times a failed job run was retried",
+				Namespace:   "",
+				Subsystem:   "",
+				ConstLabels: nil,
+			},
+			labelNames,
+		),
+		JobDeferred: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name:        "restic_job_deferred",
+				Help:        "number of runs of a job waiting for the job's window to start",
+				Namespace:   "",
+				Subsystem:   "",
+				ConstLabels: nil,
+			},
+			labelNames,
+		),
+		JobPaused: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name:        "restic_job_paused",
+				Help:        "whether a job is paused and won't start scheduled runs",
+				Namespace:   "",
+				Subsystem:   "",
+				ConstLabels: nil,
+			},
+			labelNames,
+		),
+		JobDeferredCount: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        "restic_job_deferred_total",
+				Help:        "number of scheduled job runs that were deferred until the job's window started",
+				Namespace:   "",
+				Subsystem:   "",
+				ConstLabels: nil,
+			},
+			labelNames,
+		),
+		JobOperationStartTime: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name:        "restic_job_operation_start_time",
+				Help:        "time that a backup, forget or check operation of a job was run",
+				Namespace:   "",
+				Subsystem:   "",
+				ConstLabels: nil,
+			},
+			[]string{"job", "type"},
+		),
+		JobOperationFailureCount: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name:        "restic_job_operation_failure_count",
+				Help:        "number of consecutive failures for a backup, forget or check operation of a job",
+				Namespace:   "",
+				Subsystem:   "",
+				ConstLabels: nil,
+			},
+			[]string{"job", "type"},
+		),
 		SnapshotCurrentCount: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Name:        "restic_snapshot_current_total",
@@ -81,6 +169,14 @@ func InitMetrics() *ResticMetrics {
 
 	metrics.Registry.MustRegister(metrics.JobStartTime)
 	metrics.Registry.MustRegister(metrics.JobFailureCount)
+	metrics.Registry.MustRegister(metrics.JobQueueWait)
+	metrics.Registry.MustRegister(metrics.JobSkippedCount)
+	metrics.Registry.MustRegister(metrics.JobRetryCount)
+	metrics.Registry.MustRegister(metrics.JobDeferred)
+	metrics.Registry.MustRegister(metrics.JobDeferredCount)
+	metrics.Registry.MustRegister(metrics.JobPaused)
+	metrics.Registry.MustRegister(metrics.JobOperationStartTime)
+	metrics.Registry.MustRegister(metrics.JobOperationFailureCount)
 	metrics.Registry.MustRegister(metrics.SnapshotCurrentCount)
 	metrics.Registry.MustRegister(metrics.SnapshotLatestTime)
 
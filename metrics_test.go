@@ -16,6 +16,14 @@ func TestInitMetrics(t *testing.T) {
 	assert.NotNil(t, metrics.Registry)
 	assert.NotNil(t, metrics.JobStartTime)
 	assert.NotNil(t, metrics.JobFailureCount)
+	assert.NotNil(t, metrics.JobQueueWait)
+	assert.NotNil(t, metrics.JobSkippedCount)
+	assert.NotNil(t, metrics.JobRetryCount)
+	assert.NotNil(t, metrics.JobDeferred)
+	assert.NotNil(t, metrics.JobDeferredCount)
+	assert.NotNil(t, metrics.JobPaused)
+	assert.NotNil(t, metrics.JobOperationStartTime)
+	assert.NotNil(t, metrics.JobOperationFailureCount)
 	assert.NotNil(t, metrics.SnapshotCurrentCount)
 	assert.NotNil(t, metrics.SnapshotLatestTime)
 }
@@ -0,0 +1,433 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"text/template"
+	"time"
+)
+
+const (
+	// NotifyTypeWebhook sends notifications as HTTP POST requests.
+	NotifyTypeWebhook = "webhook"
+	// NotifyTypeEmail sends notifications as emails over SMTP.
+	NotifyTypeEmail = "email"
+
+	// NotifyEventSuccess is the event of a run that succeeded.
+	NotifyEventSuccess = "success"
+	// NotifyEventFailure is the event of a run that failed.
+	NotifyEventFailure = "failure"
+	// NotifyEventRecovery is the event of a run that succeeded after the previous run of the same
+	// job type failed.
+	NotifyEventRecovery = "recovery"
+
+	// defaultNotifyTimeout limits how long each delivery attempt may take if no timeout is set.
+	defaultNotifyTimeout = 10 * time.Second
+	// notifyShutdownTimeout is how long to wait for notifications being sent before exiting.
+	notifyShutdownTimeout = 30 * time.Second
+)
+
+var (
+	// ErrNotify is returned when a notification could not be delivered.
+	ErrNotify = errors.New("notification failed")
+
+	// NotifyTypes are the accepted notify block labels.
+	NotifyTypes = NewSetFrom([]string{NotifyTypeWebhook, NotifyTypeEmail})
+	// NotifyEvents are the accepted values for on.
+	NotifyEvents = NewSetFrom([]string{NotifyEventSuccess, NotifyEventFailure, NotifyEventRecovery})
+	// defaultNotifyEvents are the events notified if on is not set.
+	defaultNotifyEvents = []string{NotifyEventFailure, NotifyEventRecovery}
+
+	notifyTemplateFuncs = template.FuncMap{
+		"json": func(value any) (string, error) {
+			out, err := json.Marshal(value)
+
+			return string(out), err
+		},
+	}
+
+	// Notifiers configured for all jobs and for each job, set with SetNotifiers
+	notifiersLock   = sync.Mutex{}
+	globalNotifiers = []NotifyConfig{}
+	jobNotifiers    = map[string][]NotifyConfig{}
+
+	// Whether the last run of each job type that wasn't skipped succeeded, to detect recoveries
+	lastOutcomesLock = sync.Mutex{}
+	lastOutcomes     = map[jobResultKey]bool{}
+
+	// notifications tracks deliveries in progress so they can finish before exiting.
+	notifications = sync.WaitGroup{}
+
+	// notifyCtx is cancelled to abandon deliveries in progress once WaitNotifications times out
+	notifyCtxLock              = sync.Mutex{}
+	notifyCtx, cancelNotifyCtx = context.WithCancel(context.Background())
+)
+
+// NotifyConfig configures where to send notifications about completed runs.
+type NotifyConfig struct {
+	Type string `hcl:"type,label"`
+	// On is the events to notify. Defaults to failure and recovery. If empty, no runs are notified,
+	// which is useful for an email notifier that only sends digests.
+	On      *[]string    `hcl:"on,optional"`
+	Timeout string       `hcl:"timeout,optional"`
+	Retry   *RetryConfig `hcl:"retry,block"`
+	// Body is a text/template for the webhook request or email body.
+	Body string `hcl:"body,optional"`
+
+	// Webhook
+	URL     string            `hcl:"url,optional"`
+	Headers map[string]string `hcl:"headers,optional"`
+
+	// Email
+	SMTPHost       string   `hcl:"smtp_host,optional"`
+	SMTPPort       int      `hcl:"smtp_port,optional"`
+	SMTPSecurity   string   `hcl:"smtp_security,optional"`
+	Username       string   `hcl:"username,optional"`
+	Password       string   `hcl:"password,optional"`
+	From           string   `hcl:"from,optional"`
+	To             []string `hcl:"to,optional"`
+	Subject        string   `hcl:"subject,optional"`
+	DigestSchedule string   `hcl:"digest_schedule,optional"`
+}
+
+// Notification is the content of a notification about a completed run. It is sent as JSON and is
+// the data for body templates.
+type Notification struct {
+	Event string `json:"event"`
+	RunRecord
+}
+
+// Validate ensures that the notify configuration is valid for its type.
+func (n NotifyConfig) Validate() error {
+	if !NotifyTypes.Contains(n.Type) {
+		return fmt.Errorf(
+			"notify has an unknown type %q, must be %s or %s: %w",
+			n.Type,
+			NotifyTypeWebhook,
+			NotifyTypeEmail,
+			ErrInvalidConfigValue,
+		)
+	}
+
+	for _, event := range n.events() {
+		if !NotifyEvents.Contains(event) {
+			return fmt.Errorf(
+				"notify %s has an unknown event %q in on, must be one of %s, %s or %s: %w",
+				n.Type,
+				event,
+				NotifyEventSuccess,
+				NotifyEventFailure,
+				NotifyEventRecovery,
+				ErrInvalidConfigValue,
+			)
+		}
+	}
+
+	if err := validateTimeout("notify timeout", n.Timeout); err != nil {
+		return err
+	}
+
+	if n.Retry != nil {
+		if err := n.Retry.Validate(); err != nil {
+			return fmt.Errorf("notify %s has an invalid retry config: %w", n.Type, err)
+		}
+
+		if len(n.Retry.RetryOn) > 0 {
+			return fmt.Errorf("notify %s retry does not support retry_on: %w", n.Type, ErrInvalidConfigValue)
+		}
+	}
+
+	if _, err := parseNotifyTemplate("body", n.Body); err != nil {
+		return fmt.Errorf("notify %s has an invalid body template: %w: %w", n.Type, err, ErrInvalidConfigValue)
+	}
+
+	if n.Type == NotifyTypeEmail {
+		return n.validateEmail()
+	}
+
+	return n.validateWebhook()
+}
+
+// validateWebhook ensures that the webhook configuration is valid.
+func (n NotifyConfig) validateWebhook() error {
+	if n.URL == "" {
+		return fmt.Errorf("notify %s is missing url: %w", n.Type, ErrMissingField)
+	}
+
+	if parsed, err := url.Parse(n.URL); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return fmt.Errorf("notify %s url must be an http or https URL: %w", n.Type, ErrInvalidConfigValue)
+	}
+
+	if n.DigestSchedule != "" {
+		return fmt.Errorf("notify %s does not support digest_schedule: %w", n.Type, ErrInvalidConfigValue)
+	}
+
+	return nil
+}
+
+// parseNotifyTemplate parses a notification template, or returns nil if text is empty.
+func parseNotifyTemplate(name, text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+
+	tmpl, err := template.New(name).Funcs(notifyTemplateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed parsing template: %w", err)
+	}
+
+	return tmpl, nil
+}
+
+// events returns the events that will be notified.
+func (n NotifyConfig) events() []string {
+	if n.On == nil {
+		return defaultNotifyEvents
+	}
+
+	return *n.On
+}
+
+// retryConfig returns the retry config for failed deliveries.
+func (n NotifyConfig) retryConfig() RetryConfig {
+	if n.Retry == nil {
+		return RetryConfig{MaxAttempts: 0, InitialDelay: "", Multiplier: 0, MaxDelay: "", RetryOn: nil}
+	}
+
+	return *n.Retry
+}
+
+// Send delivers the notification once.
+func (n NotifyConfig) Send(ctx context.Context, notification Notification) error {
+	ctx, cancel := context.WithTimeout(ctx, n.timeout())
+	defer cancel()
+
+	if n.Type == NotifyTypeEmail {
+		return n.sendEmail(ctx, notification)
+	}
+
+	return n.sendWebhook(ctx, notification)
+}
+
+// timeout returns how long each delivery attempt may take.
+func (n NotifyConfig) timeout() time.Duration {
+	if n.Timeout == "" {
+		return defaultNotifyTimeout
+	}
+
+	timeout, _ := time.ParseDuration(n.Timeout)
+
+	return timeout
+}
+
+// sendWebhook posts the notification to the webhook URL.
+func (n NotifyConfig) sendWebhook(ctx context.Context, notification Notification) error {
+	body := bytes.Buffer{}
+
+	tmpl, err := parseNotifyTemplate("body", n.Body)
+	if err != nil {
+		return err
+	}
+
+	if tmpl != nil {
+		err = tmpl.Execute(&body, notification)
+	} else {
+		err = json.NewEncoder(&body).Encode(notification)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed building webhook body: %w", err)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, &body)
+	if err != nil {
+		return fmt.Errorf("failed creating webhook request: %w", err)
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+
+	for name, value := range n.Headers {
+		request.Header.Set(name, value)
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("failed sending webhook: %w: %w", err, ErrNotify)
+	}
+
+	defer response.Body.Close()
+
+	// Drain the body so the connection can be reused
+	_, _ = io.Copy(io.Discard, response.Body)
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with %s: %w", response.Status, ErrNotify)
+	}
+
+	return nil
+}
+
+// deliver calls send until it succeeds, retrying failed attempts. What is being delivered is
+// described in the error logged if all attempts fail. Delivery is abandoned if WaitNotifications
+// times out.
+func (n NotifyConfig) deliver(description string, send func(context.Context) error) {
+	notifyCtxLock.Lock()
+	ctx := notifyCtx
+	notifyCtxLock.Unlock()
+
+	retry := n.retryConfig()
+
+	for attempt := 1; ; attempt++ {
+		err := send(ctx)
+		if err == nil {
+			return
+		}
+
+		if !retry.ShouldRetry(attempt, err) {
+			log.Printf("ERROR: Failed sending %s %s after %d attempts: %v", n.Type, description, attempt, err)
+
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Printf("ERROR: Abandoned sending %s %s after %d attempts: %v", n.Type, description, attempt, err)
+
+			return
+		case <-time.After(retry.Delay(attempt)):
+		}
+	}
+}
+
+// SetNotifiers replaces the notifiers used for completed runs with those configured for all jobs
+// and for each of the provided jobs, and schedules their digests.
+func SetNotifiers(global []NotifyConfig, jobs []Job) {
+	notifiersLock.Lock()
+	defer notifiersLock.Unlock()
+
+	globalNotifiers = slices.Clone(global)
+	jobNotifiers = make(map[string][]NotifyConfig, len(jobs))
+
+	for _, job := range jobs {
+		if len(job.Notify) > 0 {
+			jobNotifiers[job.Name] = slices.Clone(job.Notify)
+		}
+	}
+
+	setDigestsLocked(globalNotifiers, jobs)
+}
+
+// recordOutcome records whether a run succeeded and returns whether the previous run of the same
+// job type did, if it is known. Skipped runs are not recorded.
+func recordOutcome(result JobResult) (bool, bool) {
+	key := jobResultKey{jobName: result.JobName, jobType: result.JobType}
+
+	lastOutcomesLock.Lock()
+	defer lastOutcomesLock.Unlock()
+
+	previous, known := lastOutcomes[key]
+	lastOutcomes[key] = result.Success
+
+	return previous, known
+}
+
+// restoreOutcome records the outcome of a run from before a restart, unless a run has completed
+// since starting.
+func restoreOutcome(result JobResult) {
+	if result.Status == JobStatusSkipped {
+		return
+	}
+
+	key := jobResultKey{jobName: result.JobName, jobType: result.JobType}
+
+	lastOutcomesLock.Lock()
+	defer lastOutcomesLock.Unlock()
+
+	if _, ok := lastOutcomes[key]; !ok {
+		lastOutcomes[key] = result.Success
+	}
+}
+
+// notifyEventMatches returns true if a notifier for the events in on should be sent the event.
+// Recoveries are successes too.
+func notifyEventMatches(on []string, event string) bool {
+	return slices.Contains(on, event) ||
+		(event == NotifyEventRecovery && slices.Contains(on, NotifyEventSuccess))
+}
+
+// Notify sends notifications about the completed run in the background to the notifiers of the job
+// that are configured for its event and records it for digests. Skipped runs are not notified.
+func Notify(result JobResult) {
+	if result.Status == JobStatusSkipped {
+		return
+	}
+
+	record := NewRunRecord(result)
+	addToDigests(record)
+
+	previousSuccess, known := recordOutcome(result)
+
+	event := NotifyEventSuccess
+
+	switch {
+	case !result.Success:
+		event = NotifyEventFailure
+	case known && !previousSuccess:
+		event = NotifyEventRecovery
+	}
+
+	notifiersLock.Lock()
+	notifiers := slices.Concat(globalNotifiers, jobNotifiers[result.JobName])
+	notifiersLock.Unlock()
+
+	notification := Notification{Event: event, RunRecord: record}
+
+	for _, notifier := range notifiers {
+		if !notifyEventMatches(notifier.events(), event) {
+			continue
+		}
+
+		notifications.Go(func() {
+			notifier.deliver("notification for job "+result.JobName, func(ctx context.Context) error {
+				return notifier.Send(ctx, notification)
+			})
+		})
+	}
+}
+
+// WaitNotifications waits up to timeout for notifications being sent, including their retries, to
+// finish. Deliveries still in progress after timeout are abandoned. It returns false if any were.
+func WaitNotifications(timeout time.Duration) bool {
+	done := make(chan struct{})
+
+	go func() {
+		notifications.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+	}
+
+	log.Printf("Notifications still being sent after %s; abandoning them", timeout)
+
+	notifyCtxLock.Lock()
+	cancelNotifyCtx()
+	notifyCtx, cancelNotifyCtx = context.WithCancel(context.Background())
+	notifyCtxLock.Unlock()
+
+	<-done
+
+	return false
+}
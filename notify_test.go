@@ -0,0 +1,305 @@
+package main_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	main "git.iamthefij.com/iamthefij/restic-scheduler"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotifyConfigValidate(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name        string
+		notify      main.NotifyConfig
+		expectedErr error
+	}{
+		{
+			name:        "Valid webhook",
+			notify:      main.NotifyConfig{Type: "webhook", URL: "https://example.com/hook"}, //nolint:exhaustruct
+			expectedErr: nil,
+		},
+		{
+			name:        "Unknown type",
+			notify:      main.NotifyConfig{Type: "pager", URL: "https://example.com/hook"}, //nolint:exhaustruct
+			expectedErr: main.ErrInvalidConfigValue,
+		},
+		{
+			name:        "Missing url",
+			notify:      main.NotifyConfig{Type: "webhook"}, //nolint:exhaustruct
+			expectedErr: main.ErrMissingField,
+		},
+		{
+			name:        "Invalid url",
+			notify:      main.NotifyConfig{Type: "webhook", URL: "example.com/hook"}, //nolint:exhaustruct
+			expectedErr: main.ErrInvalidConfigValue,
+		},
+		{
+			name: "Unknown event",
+			notify: main.NotifyConfig{ //nolint:exhaustruct
+				Type: "webhook",
+				URL:  "https://example.com/hook",
+				On:   &[]string{"started"},
+			},
+			expectedErr: main.ErrInvalidConfigValue,
+		},
+		{
+			name: "Invalid body template",
+			notify: main.NotifyConfig{ //nolint:exhaustruct
+				Type: "webhook",
+				URL:  "https://example.com/hook",
+				Body: "{{.JobName",
+			},
+			expectedErr: main.ErrInvalidConfigValue,
+		},
+		{
+			name: "Zero timeout",
+			notify: main.NotifyConfig{ //nolint:exhaustruct
+				Type:    "webhook",
+				URL:     "https://example.com/hook",
+				Timeout: "0s",
+			},
+			expectedErr: main.ErrInvalidConfigValue,
+		},
+		{
+			name: "Retry on error classes",
+			notify: main.NotifyConfig{ //nolint:exhaustruct
+				Type:  "webhook",
+				URL:   "https://example.com/hook",
+				Retry: &main.RetryConfig{RetryOn: []string{"restic"}}, //nolint:exhaustruct
+			},
+			expectedErr: main.ErrInvalidConfigValue,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := c.notify.Validate()
+			if !errors.Is(err, c.expectedErr) {
+				t.Errorf("expected %v but found %v", c.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestNotifyConfigSend(t *testing.T) {
+	t.Parallel()
+
+	notification := main.Notification{
+		Event: main.NotifyEventFailure,
+		RunRecord: main.RunRecord{ //nolint:exhaustruct
+			JobName:  "TestNotifyJob",
+			JobType:  main.JobTypeBackup,
+			Success:  false,
+			Status:   main.JobStatusFailure,
+			Error:    `restic said "no"`,
+			Duration: time.Minute,
+		},
+	}
+
+	cases := []struct {
+		name         string
+		body         string
+		status       int
+		expectedBody string
+		expectedErr  error
+	}{
+		{
+			name:   "Default body",
+			body:   "",
+			status: http.StatusOK,
+			expectedBody: `{"event":"failure","job_name":"TestNotifyJob","job_type":"backup","trigger":"",` +
+				`"success":false,"status":"failure","error":"restic said \"no\"","start_time":"0001-01-01T00:00:00Z",` +
+				`"end_time":"0001-01-01T00:00:00Z","duration":60000000000,"queue_wait":0,"attempts":0}` + "\n",
+			expectedErr: nil,
+		},
+		{
+			name:         "Template body",
+			body:         `{"text": {{json (printf "%s %s failed after %s: %s" .JobName .JobType .Duration .Error)}}}`,
+			status:       http.StatusNoContent,
+			expectedBody: `{"text": "TestNotifyJob backup failed after 1m0s: restic said \"no\""}`,
+			expectedErr:  nil,
+		},
+		{
+			name:         "Error response",
+			body:         "",
+			status:       http.StatusInternalServerError,
+			expectedBody: "",
+			expectedErr:  main.ErrNotify,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+
+			var body []byte
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				AssertEqual(t, "unexpected method", http.MethodPost, r.Method)
+				AssertEqual(t, "unexpected header", "secret", r.Header.Get("X-Token"))
+
+				body, _ = io.ReadAll(r.Body)
+
+				w.WriteHeader(c.status)
+			}))
+			defer server.Close()
+
+			notify := main.NotifyConfig{ //nolint:exhaustruct
+				Type:    "webhook",
+				URL:     server.URL,
+				Headers: map[string]string{"X-Token": "secret"},
+				Body:    c.body,
+			}
+
+			err := notify.Send(context.Background(), notification)
+			if !errors.Is(err, c.expectedErr) {
+				t.Fatalf("expected %v but found %v", c.expectedErr, err)
+			}
+
+			if c.expectedErr == nil {
+				AssertEqual(t, "unexpected body", c.expectedBody, string(body))
+			}
+		})
+	}
+}
+
+// TestNotify replaces the configured notifiers, so it can't run in parallel.
+func TestNotify(t *testing.T) {
+	lock := sync.Mutex{}
+	events := []string{}
+	requests := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		requests++
+
+		// Fail the first delivery to check that it is retried
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		notification := main.Notification{} //nolint:exhaustruct
+		_ = json.NewDecoder(r.Body).Decode(&notification)
+		events = append(events, notification.Event)
+	}))
+	defer server.Close()
+
+	main.SetNotifiers(nil, []main.Job{
+		{ //nolint:exhaustruct
+			Name: "TestNotifyJob",
+			Notify: []main.NotifyConfig{
+				{ //nolint:exhaustruct
+					Type:  "webhook",
+					URL:   server.URL,
+					Retry: &main.RetryConfig{MaxAttempts: 2, InitialDelay: "1ms"}, //nolint:exhaustruct
+				},
+			},
+		},
+	})
+	t.Cleanup(func() { main.SetNotifiers(nil, nil) })
+
+	complete := func(success bool, status string) {
+		main.JobComplete(main.JobResult{ //nolint:exhaustruct
+			JobName: "TestNotifyJob",
+			JobType: main.JobTypeBackup,
+			Success: success,
+			Status:  status,
+		})
+		main.WaitNotifications(time.Minute)
+	}
+
+	// By default only failures and recoveries are notified. Skipped runs don't hide a recovery.
+	complete(true, main.JobStatusSuccess)
+	complete(false, main.JobStatusFailure)
+	complete(true, main.JobStatusSkipped)
+	complete(true, main.JobStatusSuccess)
+	complete(false, main.JobStatusFailure)
+	complete(true, main.JobStatusSuccess)
+
+	// Jobs without notifiers aren't notified
+	main.JobComplete(main.JobResult{ //nolint:exhaustruct
+		JobName: "TestNotifyOtherJob",
+		JobType: main.JobTypeBackup,
+		Success: false,
+		Status:  main.JobStatusFailure,
+	})
+	main.WaitNotifications(time.Minute)
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	assert.Equal(t, []string{
+		main.NotifyEventFailure,
+		main.NotifyEventRecovery,
+		main.NotifyEventFailure,
+		main.NotifyEventRecovery,
+	}, events)
+	AssertEqual(t, "unexpected request count", 5, requests)
+}
+
+// TestWaitNotificationsTimeout sets the notifiers, so it can't run in parallel.
+func TestWaitNotificationsTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	main.SetNotifiers([]main.NotifyConfig{
+		{ //nolint:exhaustruct
+			Type:  "webhook",
+			URL:   server.URL,
+			Retry: &main.RetryConfig{MaxAttempts: 10, InitialDelay: "1h"}, //nolint:exhaustruct
+		},
+	}, nil)
+	t.Cleanup(func() { main.SetNotifiers(nil, nil) })
+
+	main.JobComplete(main.JobResult{ //nolint:exhaustruct
+		JobName: "TestWaitNotificationsTimeout",
+		JobType: main.JobTypeBackup,
+		Success: false,
+		Status:  main.JobStatusFailure,
+	})
+
+	// Retries of the failing webhook are abandoned rather than waited for
+	start := time.Now()
+
+	assert.False(t, main.WaitNotifications(50*time.Millisecond))
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected WaitNotifications to return soon after its timeout, took %s", elapsed)
+	}
+
+	// Notifications sent afterwards aren't abandoned
+	delivered := make(chan struct{}, 1)
+	working := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		delivered <- struct{}{}
+	}))
+	defer working.Close()
+
+	main.SetNotifiers([]main.NotifyConfig{{Type: "webhook", URL: working.URL}}, nil) //nolint:exhaustruct
+
+	main.JobComplete(main.JobResult{ //nolint:exhaustruct
+		JobName: "TestWaitNotificationsTimeout",
+		JobType: main.JobTypeBackup,
+		Success: false,
+		Status:  main.JobStatusFailure,
+	})
+
+	assert.True(t, main.WaitNotifications(time.Minute))
+	assert.Len(t, delivered, 1)
+}
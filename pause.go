@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+const (
+	// SkipReasonPaused is the reason recorded when a run is skipped because its job is paused.
+	SkipReasonPaused = "paused"
+
+	// pauseFileName is the name of the file paused jobs are kept in within the state dir.
+	pauseFileName = "paused.json"
+)
+
+// PauseState lists the paused jobs and whether all jobs are paused.
+type PauseState struct {
+	All  bool     `json:"all"`
+	Jobs []string `json:"jobs"`
+}
+
+// LoadPauseState restores which jobs are paused from the state dir, if saved there, and saves any
+// later changes to it so they are kept across restarts.
+func (s *Scheduler) LoadPauseState(stateDir string) error {
+	path := filepath.Join(stateDir, pauseFileName)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pauseFile = path
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed reading pause state %s: %w", path, err)
+	}
+
+	var state PauseState
+	if err := json.Unmarshal(content, &state); err != nil {
+		return fmt.Errorf("failed decoding pause state %s: %w", path, err)
+	}
+
+	s.pausedAll = state.All
+	s.paused = NewSetFrom(state.Jobs)
+
+	if s.started {
+		s.prunePausedLocked()
+	}
+
+	return nil
+}
+
+// Pause stops the named job from starting scheduled runs until it is resumed. Runs already queued
+// or in progress are not affected.
+func (s *Scheduler) Pause(name string) error {
+	return s.setPaused(name, true)
+}
+
+// Resume allows the named job to start scheduled runs again. The job stays paused if all jobs are paused.
+func (s *Scheduler) Resume(name string) error {
+	return s.setPaused(name, false)
+}
+
+// PauseAll stops all jobs from starting scheduled runs until they are resumed with ResumeAll.
+func (s *Scheduler) PauseAll() error {
+	return s.setPausedAll(true)
+}
+
+// ResumeAll allows jobs to start scheduled runs again, except for those paused individually.
+func (s *Scheduler) ResumeAll() error {
+	return s.setPausedAll(false)
+}
+
+// IsPaused returns true if the named job, or all jobs, are paused.
+func (s *Scheduler) IsPaused(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.isPausedLocked(name)
+}
+
+// PauseState returns which jobs are paused.
+func (s *Scheduler) PauseState() PauseState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.pauseStateLocked()
+}
+
+func (s *Scheduler) setPaused(name string, paused bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.entries[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrJobNotFound, name)
+	}
+
+	if paused {
+		log.Printf("Pausing job %s", name)
+
+		s.paused[name] = true
+	} else {
+		log.Printf("Resuming job %s", name)
+
+		delete(s.paused, name)
+	}
+
+	return s.pauseChangedLocked()
+}
+
+func (s *Scheduler) setPausedAll(paused bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if paused {
+		log.Println("Pausing all jobs")
+	} else {
+		log.Println("Resuming all jobs")
+	}
+
+	s.pausedAll = paused
+
+	return s.pauseChangedLocked()
+}
+
+// isPausedLocked returns true if the named job, or all jobs, are paused. The caller must hold s.mu.
+func (s *Scheduler) isPausedLocked(name string) bool {
+	return s.pausedAll || s.paused.Contains(name)
+}
+
+// pauseStateLocked returns which jobs are paused. The caller must hold s.mu.
+func (s *Scheduler) pauseStateLocked() PauseState {
+	jobs := make([]string, 0, len(s.paused))
+	for name := range s.paused {
+		jobs = append(jobs, name)
+	}
+
+	slices.Sort(jobs)
+
+	return PauseState{All: s.pausedAll, Jobs: jobs}
+}
+
+// prunePausedLocked forgets the paused state of jobs that are no longer scheduled and updates the
+// paused metric. The caller must hold s.mu.
+func (s *Scheduler) prunePausedLocked() {
+	for name := range s.paused {
+		if _, ok := s.entries[name]; !ok {
+			delete(s.paused, name)
+		}
+	}
+
+	Metrics.JobPaused.Reset()
+
+	for name := range s.entries {
+		Metrics.JobPaused.WithLabelValues(name).Set(boolToFloat(s.isPausedLocked(name)))
+	}
+}
+
+// pauseChangedLocked updates the paused metric and saves the pause state to the state dir, if one
+// was loaded. The caller must hold s.mu.
+func (s *Scheduler) pauseChangedLocked() error {
+	s.prunePausedLocked()
+
+	if s.pauseFile == "" {
+		return nil
+	}
+
+	content, err := json.Marshal(s.pauseStateLocked())
+	if err != nil {
+		return fmt.Errorf("failed encoding pause state: %w", err)
+	}
+
+	if err := os.WriteFile(s.pauseFile, content, 0o640); err != nil {
+		return fmt.Errorf("failed saving pause state %s: %w", s.pauseFile, err)
+	}
+
+	return nil
+}
+
+// boolToFloat returns 1 for true and 0 for false, for use as a metric value.
+func boolToFloat(value bool) float64 {
+	if value {
+		return 1.0
+	}
+
+	return 0.0
+}
+
+// PauseHandleFunc pauses or resumes the job named in the path, or all jobs if the path has no name.
+// It responds with which jobs are paused.
+func PauseHandleFunc(writer http.ResponseWriter, request *http.Request, sched *Scheduler, paused bool) {
+	var err error
+
+	name := request.PathValue("name")
+
+	switch {
+	case name == "" && paused:
+		err = sched.PauseAll()
+	case name == "":
+		err = sched.ResumeAll()
+	case paused:
+		err = sched.Pause(name)
+	default:
+		err = sched.Resume(name)
+	}
+
+	switch {
+	case errors.Is(err, ErrJobNotFound):
+		http.Error(writer, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(writer).Encode(sched.PauseState()); err != nil {
+		http.Error(writer, "failed writing json for pause state", http.StatusInternalServerError)
+	}
+}
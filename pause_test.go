@@ -0,0 +1,160 @@
+package main_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	main "git.iamthefij.com/iamthefij/restic-scheduler"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchedulerPause(t *testing.T) {
+	t.Parallel()
+
+	sched := main.NewScheduler()
+
+	err := sched.Start([]main.Job{
+		{Name: "first", Schedule: "@daily"},  //nolint:exhaustruct
+		{Name: "second", Schedule: "@daily"}, //nolint:exhaustruct
+	})
+	AssertEqualFail(t, "unexpected error starting scheduler", nil, err)
+
+	defer sched.StopGraceful(0)
+
+	err = sched.Pause("missing")
+	if !errors.Is(err, main.ErrJobNotFound) {
+		t.Errorf("expected %v but found %v", main.ErrJobNotFound, err)
+	}
+
+	AssertEqualFail(t, "unexpected error pausing job", nil, sched.Pause("first"))
+	assert.True(t, sched.IsPaused("first"))
+	assert.False(t, sched.IsPaused("second"))
+	assert.True(t, sched.Active().Jobs["first"].Paused)
+
+	// Pausing all jobs takes precedence over resuming a single job
+	AssertEqualFail(t, "unexpected error pausing all jobs", nil, sched.PauseAll())
+	AssertEqualFail(t, "unexpected error resuming job", nil, sched.Resume("first"))
+	assert.True(t, sched.IsPaused("first"))
+	assert.True(t, sched.IsPaused("second"))
+	assert.True(t, sched.Active().PausedAll)
+
+	AssertEqualFail(t, "unexpected error resuming all jobs", nil, sched.ResumeAll())
+	assert.False(t, sched.IsPaused("first"))
+	assert.False(t, sched.IsPaused("second"))
+
+	// Paused state is kept for jobs that remain after a reload
+	AssertEqualFail(t, "unexpected error pausing job", nil, sched.Pause("second"))
+
+	err = sched.ReplaceJobs([]main.Job{
+		{Name: "second", Schedule: "@hourly"}, //nolint:exhaustruct
+	})
+	AssertEqualFail(t, "unexpected error replacing jobs", nil, err)
+	assert.True(t, sched.IsPaused("second"))
+	assert.Equal(t, main.PauseState{All: false, Jobs: []string{"second"}}, sched.PauseState())
+}
+
+func TestSchedulerPauseStatePersisted(t *testing.T) {
+	t.Parallel()
+
+	stateDir := t.TempDir()
+	jobs := []main.Job{
+		{Name: "first", Schedule: "@daily"},  //nolint:exhaustruct
+		{Name: "second", Schedule: "@daily"}, //nolint:exhaustruct
+	}
+
+	sched := main.NewScheduler()
+	AssertEqualFail(t, "unexpected error loading pause state", nil, sched.LoadPauseState(stateDir))
+	AssertEqualFail(t, "unexpected error starting scheduler", nil, sched.Start(jobs))
+	AssertEqualFail(t, "unexpected error pausing job", nil, sched.Pause("first"))
+	sched.StopGraceful(0)
+
+	restarted := main.NewScheduler()
+	AssertEqualFail(t, "unexpected error loading pause state", nil, restarted.LoadPauseState(stateDir))
+	AssertEqualFail(t, "unexpected error starting scheduler", nil, restarted.Start(jobs))
+
+	defer restarted.StopGraceful(0)
+
+	assert.True(t, restarted.IsPaused("first"))
+	assert.False(t, restarted.IsPaused("second"))
+}
+
+func TestPauseHandleFunc(t *testing.T) {
+	t.Parallel()
+
+	sched := main.NewScheduler()
+
+	err := sched.Start([]main.Job{
+		{Name: "first", Schedule: "@daily"}, //nolint:exhaustruct
+	})
+	AssertEqualFail(t, "unexpected error starting scheduler", nil, err)
+
+	t.Cleanup(func() { sched.StopGraceful(0) })
+
+	cases := []struct {
+		name          string
+		jobName       string
+		paused        bool
+		expectedCode  int
+		expectedState main.PauseState
+	}{
+		{
+			name:          "Pause job",
+			jobName:       "first",
+			paused:        true,
+			expectedCode:  http.StatusOK,
+			expectedState: main.PauseState{All: false, Jobs: []string{"first"}},
+		},
+		{
+			name:          "Pause all",
+			jobName:       "",
+			paused:        true,
+			expectedCode:  http.StatusOK,
+			expectedState: main.PauseState{All: true, Jobs: []string{"first"}},
+		},
+		{
+			name:          "Resume all",
+			jobName:       "",
+			paused:        false,
+			expectedCode:  http.StatusOK,
+			expectedState: main.PauseState{All: false, Jobs: []string{"first"}},
+		},
+		{
+			name:          "Resume job",
+			jobName:       "first",
+			paused:        false,
+			expectedCode:  http.StatusOK,
+			expectedState: main.PauseState{All: false, Jobs: []string{}},
+		},
+		{
+			name:          "Unknown job",
+			jobName:       "missing",
+			paused:        true,
+			expectedCode:  http.StatusNotFound,
+			expectedState: main.PauseState{}, //nolint:exhaustruct
+		},
+	}
+
+	// Cases are run in order since each depends on the state left by the previous
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodPost, "/pause", nil)
+		req.SetPathValue("name", c.jobName)
+
+		rr := httptest.NewRecorder()
+		main.PauseHandleFunc(rr, req, sched, c.paused)
+
+		assert.Equal(t, c.expectedCode, rr.Code, c.name)
+
+		if c.expectedCode != http.StatusOK {
+			continue
+		}
+
+		var state main.PauseState
+
+		err := json.NewDecoder(rr.Body).Decode(&state)
+		AssertEqualFail(t, "unexpected error decoding pause state", nil, err)
+		assert.Equal(t, c.expectedState, state, c.name)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+var (
+	// ErrTimeout is returned when a job or task runs longer than its configured timeout.
+	ErrTimeout = errors.New("timeout exceeded")
+
+	// KillGracePeriod is how long a child process group has to exit after SIGTERM before it is sent SIGKILL.
+	KillGracePeriod = 10 * time.Second
+)
+
+// setProcessGroup starts cmd in its own process group. When the command's context is done, the
+// whole group is sent SIGTERM, followed by SIGKILL after KillGracePeriod, so that any children of
+// the command are stopped as well. The returned function must be called once the command has been
+// waited for so that SIGKILL isn't sent to a process group ID that may have been reused.
+func setProcessGroup(cmd *exec.Cmd) func() {
+	var kill *time.Timer
+
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true} //nolint:exhaustruct
+	cmd.Cancel = func() error {
+		pgid := cmd.Process.Pid
+
+		if err := syscall.Kill(-pgid, syscall.SIGTERM); err != nil {
+			return fmt.Errorf("failed terminating process group %d: %w", pgid, err)
+		}
+
+		kill = time.AfterFunc(KillGracePeriod, func() {
+			_ = syscall.Kill(-pgid, syscall.SIGKILL)
+		})
+
+		return nil
+	}
+
+	// Wait doesn't return until Cancel has returned, so kill is set by the time this is called
+	return func() {
+		if kill != nil {
+			kill.Stop()
+		}
+	}
+}
+
+// contextError describes why ctx is done. If ctx was given a cause, such as ErrWindowClosed, the
+// error wraps it. Otherwise, if the deadline was exceeded the error wraps ErrTimeout.
+func contextError(ctx context.Context) error {
+	err := ctx.Err()
+	if cause := context.Cause(ctx); cause != nil && !errors.Is(cause, err) {
+		return fmt.Errorf("%w: %w", cause, err)
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	}
+
+	return err
+}
+
+// withOptionalTimeout returns a child of ctx that expires after timeout, if one is set. The timeout
+// is expected to have already been validated.
+func withOptionalTimeout(ctx context.Context, timeout string) (context.Context, context.CancelFunc) {
+	if timeout == "" {
+		return context.WithCancel(ctx)
+	}
+
+	duration, _ := time.ParseDuration(timeout)
+
+	return context.WithTimeout(ctx, duration)
+}
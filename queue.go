@@ -0,0 +1,313 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"slices"
+	"sync"
+	"time"
+)
+
+// QueuedRun is a single requested run of a job that is either waiting in a RunQueue or executing.
+type QueuedRun struct {
+	ID        string
+	Job       Job
+	JobType   string
+	Trigger   string
+	QueuedAt  time.Time
+	StartedAt time.Time
+	cancel    context.CancelCauseFunc
+	done      chan struct{}
+	// result is set once the run has finished executing. It is only safe to read after done is closed.
+	result *JobResult
+}
+
+// QueueWait returns how long the run waited in the queue before it was started.
+func (r *QueuedRun) QueueWait() time.Duration {
+	if r.StartedAt.IsZero() {
+		return time.Since(r.QueuedAt)
+	}
+
+	return r.StartedAt.Sub(r.QueuedAt)
+}
+
+// Done returns a channel that is closed once the run has finished executing or was dropped from the queue.
+func (r *QueuedRun) Done() <-chan struct{} {
+	return r.done
+}
+
+// repo returns the key used to serialize runs against the same restic repository.
+func (r *QueuedRun) repo() string {
+	if r.Job.Config == nil {
+		return ""
+	}
+
+	return r.Job.Config.Repo
+}
+
+// RunQueue is a worker pool for job runs. It orders requested runs so that no two runs against
+// the same restic repository overlap and no more than a maximum number of runs execute at once.
+// Runs are started in the order they were submitted unless their repository is still in use.
+type RunQueue struct {
+	mu            sync.Mutex
+	pending       []*QueuedRun
+	running       []*QueuedRun
+	busyRepos     Set
+	maxConcurrent int
+	wg            sync.WaitGroup
+	execute       func(context.Context, *QueuedRun)
+}
+
+// NewRunQueue creates a RunQueue that calls execute for each run once it is allowed to start. The
+// context passed to execute is cancelled if the queue is stopped.
+func NewRunQueue(execute func(context.Context, *QueuedRun)) *RunQueue {
+	return &RunQueue{
+		mu:            sync.Mutex{},
+		pending:       []*QueuedRun{},
+		running:       []*QueuedRun{},
+		busyRepos:     Set{},
+		maxConcurrent: 0,
+		wg:            sync.WaitGroup{},
+		execute:       execute,
+	}
+}
+
+// SetMaxConcurrent sets the maximum number of runs that may execute at once. A value of 0
+// removes the limit. Runs that are already executing are not interrupted.
+func (q *RunQueue) SetMaxConcurrent(maxConcurrent int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.maxConcurrent = maxConcurrent
+	q.dispatchLocked()
+}
+
+// RunningJobNames returns the names of jobs with a run currently executing.
+func (q *RunQueue) RunningJobNames() []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return runJobNames(q.running)
+}
+
+// QueuedJobNames returns the names of jobs with a run waiting to start, in queue order.
+func (q *RunQueue) QueuedJobNames() []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return runJobNames(q.pending)
+}
+
+// State returns whether the run is queued, running, finished or was dropped from the queue.
+func (q *RunQueue) State(run *QueuedRun) string {
+	select {
+	case <-run.done:
+		if run.result == nil {
+			return RunStateDropped
+		}
+
+		return RunStateFinished
+	default:
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if slices.Contains(q.pending, run) {
+		return JobStateQueued
+	}
+
+	return JobStateRunning
+}
+
+func runJobNames(runs []*QueuedRun) []string {
+	names := make([]string, 0, len(runs))
+	for _, run := range runs {
+		names = append(names, run.Job.Name)
+	}
+
+	return names
+}
+
+// full returns true if no more runs may be started. The caller must hold q.mu.
+func (q *RunQueue) full() bool {
+	return q.maxConcurrent > 0 && len(q.running) >= q.maxConcurrent
+}
+
+// Submit adds a run of the provided job to the queue and returns it. The run is started as soon
+// as a worker is free and no other run is using the same repository. The job type is the
+// operation to run and the trigger records what caused the run.
+func (q *RunQueue) Submit(job Job, jobType, trigger string) *QueuedRun {
+	run := &QueuedRun{
+		ID:        rand.Text(),
+		Job:       job,
+		JobType:   jobType,
+		Trigger:   trigger,
+		QueuedAt:  time.Now(),
+		StartedAt: time.Time{},
+		cancel:    nil,
+		done:      make(chan struct{}),
+		result:    nil,
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.busyRepos.Contains(run.repo()) {
+		job.Logger().Printf("Queued behind another run using the same repository")
+	} else if q.full() {
+		job.Logger().Printf("Queued until one of %d running jobs completes", q.maxConcurrent)
+	}
+
+	q.wg.Add(1)
+	q.pending = append(q.pending, run)
+	q.dispatchLocked()
+
+	return run
+}
+
+// Stop drops all pending runs and cancels every running run with the provided cause. It returns the
+// runs that were cancelled. Use Wait to block until they have exited. The queue can continue to be
+// used afterwards.
+func (q *RunQueue) Stop(cause error) []*QueuedRun {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, run := range q.pending {
+		run.Job.Logger().Printf("Dropping queued run")
+		close(run.done)
+		q.wg.Done()
+	}
+
+	q.pending = []*QueuedRun{}
+
+	cancelled := make([]*QueuedRun, len(q.running))
+	copy(cancelled, q.running)
+
+	for _, run := range cancelled {
+		run.cancel(cause)
+	}
+
+	return cancelled
+}
+
+// Cancel drops the pending runs of the named job and cancels its running runs with the provided
+// cause. Dropped runs are given a cancelled result, which is not yet recorded. It returns the runs
+// that were dropped or cancelled. Runs that were dropped have no StartedAt time. Use their Done
+// channels to wait for cancelled runs to exit.
+func (q *RunQueue) Cancel(jobName string, cause error) []*QueuedRun {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	cancelled := []*QueuedRun{}
+	remaining := q.pending[:0]
+
+	for _, run := range q.pending {
+		if run.Job.Name != jobName {
+			remaining = append(remaining, run)
+
+			continue
+		}
+
+		run.Job.Logger().Printf("Dropping queued run")
+
+		result := run.Job.cancelledResult(RunInfo{
+			QueueWait: run.QueueWait(),
+			Trigger:   run.Trigger,
+			JobType:   run.JobType,
+			RunID:     run.ID,
+		}, cause)
+		run.result = &result
+
+		close(run.done)
+		q.wg.Done()
+
+		cancelled = append(cancelled, run)
+	}
+
+	q.pending = remaining
+
+	for _, run := range q.running {
+		if run.Job.Name == jobName {
+			run.Job.Logger().Printf("Cancelling running %s run", run.JobType)
+			run.cancel(cause)
+
+			cancelled = append(cancelled, run)
+		}
+	}
+
+	return cancelled
+}
+
+// Drain blocks until no runs are queued or executing.
+func (q *RunQueue) Drain() {
+	q.wg.Wait()
+}
+
+// Wait blocks until no runs are queued or executing or the timeout has passed. It returns false if
+// runs were still queued or executing when the timeout passed.
+func (q *RunQueue) Wait(timeout time.Duration) bool {
+	done := make(chan struct{})
+
+	go func() {
+		q.Drain()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// dispatchLocked starts pending runs, in order, while there are free workers. Runs whose repository
+// is in use are skipped and stay queued. The caller must hold q.mu.
+func (q *RunQueue) dispatchLocked() {
+	remaining := q.pending[:0]
+
+	for _, run := range q.pending {
+		repo := run.repo()
+		if q.full() || q.busyRepos.Contains(repo) {
+			remaining = append(remaining, run)
+
+			continue
+		}
+
+		ctx, cancel := context.WithCancelCause(context.Background())
+
+		q.busyRepos[repo] = true
+		q.running = append(q.running, run)
+		run.StartedAt = time.Now()
+		run.cancel = cancel
+
+		go q.run(ctx, run)
+	}
+
+	q.pending = remaining
+}
+
+// run executes a run and releases its repository for the next pending run once complete.
+func (q *RunQueue) run(ctx context.Context, run *QueuedRun) {
+	defer q.wg.Done()
+	defer close(run.done)
+	defer run.cancel(nil)
+
+	q.execute(ctx, run)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	delete(q.busyRepos, run.repo())
+
+	for i, running := range q.running {
+		if running == run {
+			q.running = append(q.running[:i], q.running[i+1:]...)
+
+			break
+		}
+	}
+
+	q.dispatchLocked()
+}
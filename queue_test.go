@@ -0,0 +1,155 @@
+package main_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	main "git.iamthefij.com/iamthefij/restic-scheduler"
+	"github.com/stretchr/testify/assert"
+)
+
+func queueTestJob(name, repo string) main.Job {
+	return main.Job{ //nolint:exhaustruct
+		Name:     name,
+		Schedule: "@daily",
+		Config:   &main.ResticConfig{Repo: repo, Passphrase: "shh"}, //nolint:exhaustruct
+	}
+}
+
+func TestRunQueueRepoLocking(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name          string
+		repos         []string
+		maxConcurrent int32
+		expectWait    bool
+	}{
+		{
+			name:          "same repo runs serially",
+			repos:         []string{"repo", "repo"},
+			maxConcurrent: 1,
+			expectWait:    true,
+		},
+		{
+			name:          "different repos run concurrently",
+			repos:         []string{"repo1", "repo2"},
+			maxConcurrent: 2,
+			expectWait:    false,
+		},
+	}
+
+	for _, c := range cases {
+		testCase := c
+
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			var running, maxRunning atomic.Int32
+
+			queue := main.NewRunQueue(func(_ context.Context, run *main.QueuedRun) {
+				current := running.Add(1)
+				defer running.Add(-1)
+
+				for {
+					observed := maxRunning.Load()
+					if current <= observed || maxRunning.CompareAndSwap(observed, current) {
+						break
+					}
+				}
+
+				time.Sleep(50 * time.Millisecond)
+			})
+
+			runs := []*main.QueuedRun{}
+			for i, repo := range testCase.repos {
+				runs = append(runs, queue.Submit(queueTestJob(string(rune('a'+i)), repo), main.JobTypeBackup, main.TriggerSchedule))
+			}
+
+			wg := sync.WaitGroup{}
+			for _, run := range runs {
+				wg.Go(func() { <-run.Done() })
+			}
+
+			wg.Wait()
+
+			assert.Equal(t, testCase.maxConcurrent, maxRunning.Load())
+			assert.Equal(t, testCase.expectWait, runs[1].QueueWait() >= 40*time.Millisecond)
+		})
+	}
+}
+
+func TestRunQueueMaxConcurrent(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	started := make(chan string, 3)
+
+	queue := main.NewRunQueue(func(_ context.Context, run *main.QueuedRun) {
+		started <- run.Job.Name
+		<-release
+	})
+	queue.SetMaxConcurrent(1)
+
+	runs := []*main.QueuedRun{
+		queue.Submit(queueTestJob("first", "repo1"), main.JobTypeBackup, main.TriggerSchedule),
+		queue.Submit(queueTestJob("second", "repo2"), main.JobTypeBackup, main.TriggerSchedule),
+		queue.Submit(queueTestJob("third", "repo3"), main.JobTypeBackup, main.TriggerSchedule),
+	}
+
+	assert.Equal(t, "first", <-started)
+	assert.Equal(t, []string{"first"}, queue.RunningJobNames())
+	assert.Equal(t, []string{"second", "third"}, queue.QueuedJobNames())
+
+	// Raising the limit should start the next queued run in FIFO order
+	queue.SetMaxConcurrent(2)
+	assert.Equal(t, "second", <-started)
+	assert.Equal(t, []string{"third"}, queue.QueuedJobNames())
+
+	close(release)
+
+	for _, run := range runs {
+		<-run.Done()
+	}
+
+	assert.Empty(t, queue.RunningJobNames())
+	assert.Empty(t, queue.QueuedJobNames())
+}
+
+func TestRunQueueStop(t *testing.T) {
+	t.Parallel()
+
+	started := make(chan struct{})
+
+	var cause error
+
+	queue := main.NewRunQueue(func(ctx context.Context, _ *main.QueuedRun) {
+		close(started)
+		<-ctx.Done()
+
+		cause = context.Cause(ctx)
+	})
+	queue.SetMaxConcurrent(1)
+
+	running := queue.Submit(queueTestJob("running", "repo1"), main.JobTypeBackup, main.TriggerSchedule)
+	queued := queue.Submit(queueTestJob("queued", "repo2"), main.JobTypeBackup, main.TriggerSchedule)
+
+	<-started
+
+	cancelled := queue.Stop(main.ErrStopped)
+
+	assert.Len(t, cancelled, 1)
+	assert.Equal(t, "running", cancelled[0].Job.Name)
+	assert.True(t, queue.Wait(time.Second), "cancelled run should exit")
+
+	<-running.Done()
+	<-queued.Done()
+
+	assert.ErrorIs(t, cause, main.ErrStopped)
+
+	assert.Empty(t, queue.RunningJobNames())
+	assert.Empty(t, queue.QueuedJobNames())
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -91,6 +92,22 @@ func (ro RestoreOpts) ToArgs() (args []string) {
 	return
 }
 
+// CheckOpts holds optional arguments for the Restic check command.
+type CheckOpts struct {
+	ReadData       bool   `hcl:"ReadData,optional"`
+	ReadDataSubset string `hcl:"ReadDataSubset,optional"`
+	WithCache      bool   `hcl:"WithCache,optional"`
+}
+
+// ToArgs returns the structs arguments as a slice of strings.
+func (co CheckOpts) ToArgs() (args []string) {
+	args = maybeAddArgBool(args, "--read-data", co.ReadData)
+	args = maybeAddArgString(args, "--read-data-subset", co.ReadDataSubset)
+	args = maybeAddArgBool(args, "--with-cache", co.WithCache)
+
+	return
+}
+
 type TagList []string
 
 func (t TagList) String() string {
@@ -258,7 +275,9 @@ func (e *ResticError) Unwrap() error {
 	return e.OriginalError
 }
 
+// RunRestic runs a restic command. If ctx is done before the command exits, restic is killed.
 func (rcmd Restic) RunRestic(
+	ctx context.Context,
 	command string,
 	options CommandOptions,
 	commandArgs ...string,
@@ -272,7 +291,9 @@ func (rcmd Restic) RunRestic(
 	args = append(args, options.ToArgs()...)
 	args = append(args, commandArgs...)
 
-	cmd := exec.Command("restic", args...)
+	cmd := exec.CommandContext(ctx, "restic", args...)
+	stopKill := setProcessGroup(cmd)
+	defer stopKill()
 
 	output := NewCapturedCommandLogWriter(rcmd.Logger)
 	cmd.Stdout = output.Stdout
@@ -290,38 +311,42 @@ func (rcmd Restic) RunRestic(
 			responseErr = ErrRepoNotFound
 		}
 
+		if ctx.Err() != nil {
+			responseErr = errors.Join(responseErr, contextError(ctx))
+		}
+
 		return output, NewResticError(command, output.AllLines(), errors.Join(err, responseErr))
 	}
 
 	return output, nil
 }
 
-func (rcmd Restic) Backup(files []string, opts BackupOpts) error {
-	_, err := rcmd.RunRestic("backup", opts, files...)
+func (rcmd Restic) Backup(ctx context.Context, files []string, opts BackupOpts) error {
+	_, err := rcmd.RunRestic(ctx, "backup", opts, files...)
 
 	return err
 }
 
-func (rcmd Restic) Restore(snapshot string, opts RestoreOpts) error {
-	_, err := rcmd.RunRestic("restore", opts, snapshot)
+func (rcmd Restic) Restore(ctx context.Context, snapshot string, opts RestoreOpts) error {
+	_, err := rcmd.RunRestic(ctx, "restore", opts, snapshot)
 
 	return err
 }
 
-func (rcmd Restic) Forget(forgetOpts ForgetOpts) error {
-	_, err := rcmd.RunRestic("forget", forgetOpts)
+func (rcmd Restic) Forget(ctx context.Context, forgetOpts ForgetOpts) error {
+	_, err := rcmd.RunRestic(ctx, "forget", forgetOpts)
 
 	return err
 }
 
-func (rcmd Restic) Check() error {
-	_, err := rcmd.RunRestic("check", NoOpts{})
+func (rcmd Restic) Check(ctx context.Context, checkOpts CheckOpts) error {
+	_, err := rcmd.RunRestic(ctx, "check", checkOpts)
 
 	return err
 }
 
-func (rcmd Restic) Unlock(unlockOpts UnlockOpts) error {
-	_, err := rcmd.RunRestic("unlock", unlockOpts)
+func (rcmd Restic) Unlock(ctx context.Context, unlockOpts UnlockOpts) error {
+	_, err := rcmd.RunRestic(ctx, "unlock", unlockOpts)
 
 	return err
 }
@@ -339,8 +364,8 @@ type Snapshot struct {
 	Tags     []string  `json:"tags,omitempty"`
 }
 
-func (rcmd Restic) ReadSnapshots() ([]Snapshot, error) {
-	output, err := rcmd.RunRestic("snapshots", GenericOpts{"--json"})
+func (rcmd Restic) ReadSnapshots(ctx context.Context) ([]Snapshot, error) {
+	output, err := rcmd.RunRestic(ctx, "snapshots", GenericOpts{"--json"})
 	if err != nil {
 		return nil, err
 	}
@@ -359,15 +384,15 @@ func (rcmd Restic) ReadSnapshots() ([]Snapshot, error) {
 	return *snapshots, nil
 }
 
-func (rcmd Restic) Snapshots() error {
-	_, err := rcmd.RunRestic("snapshots", NoOpts{})
+func (rcmd Restic) Snapshots(ctx context.Context) error {
+	_, err := rcmd.RunRestic(ctx, "snapshots", NoOpts{})
 
 	return err
 }
 
-func (rcmd Restic) EnsureInit() error {
-	if err := rcmd.Snapshots(); errors.Is(err, ErrRepoNotFound) {
-		_, err := rcmd.RunRestic("init", NoOpts{})
+func (rcmd Restic) EnsureInit(ctx context.Context) error {
+	if err := rcmd.Snapshots(ctx); errors.Is(err, ErrRepoNotFound) {
+		_, err := rcmd.RunRestic(ctx, "init", NoOpts{})
 
 		return err
 	}
@@ -166,6 +166,23 @@ func TestUnlockOpts(t *testing.T) {
 	AssertEqual(t, "args didn't match", expected, args)
 }
 
+func TestCheckOpts(t *testing.T) {
+	t.Parallel()
+
+	args := main.CheckOpts{
+		ReadData:       false,
+		ReadDataSubset: "10%",
+		WithCache:      true,
+	}.ToArgs()
+
+	expected := []string{
+		"--read-data-subset", "10%",
+		"--with-cache",
+	}
+
+	AssertEqual(t, "args didn't match", expected, args)
+}
+
 func TestBuildEnv(t *testing.T) {
 	t.Parallel()
 
@@ -241,32 +258,32 @@ func TestResticInterface(t *testing.T) {
 	AssertEqualFail(t, "unexpected error writing to test file", nil, err)
 
 	// Make sure no existing repo is found
-	_, err = restic.ReadSnapshots()
+	_, err = restic.ReadSnapshots(t.Context())
 	if err == nil || !errors.Is(err, main.ErrRepoNotFound) {
 		AssertEqualFail(t, "didn't get expected error for backup", main.ErrRepoNotFound.Error(), err.Error())
 	}
 
 	// Try to backup when repo is not initialized
-	err = restic.Backup([]string{dataDir}, main.BackupOpts{}) //nolint:exhaustruct
+	err = restic.Backup(t.Context(), []string{dataDir}, main.BackupOpts{}) //nolint:exhaustruct
 	if !errors.Is(err, main.ErrRepoNotFound) {
 		AssertEqualFail(t, "unexpected error creating making backup", nil, err)
 	}
 
 	// Init repo
-	err = restic.EnsureInit()
+	err = restic.EnsureInit(t.Context())
 	AssertEqualFail(t, "unexpected error initializing repo", nil, err)
 
 	// Verify it can be reinitialized with no issues
-	err = restic.EnsureInit()
+	err = restic.EnsureInit(t.Context())
 	AssertEqualFail(t, "unexpected error reinitializing repo", nil, err)
 
 	// Backup for real this time
-	err = restic.Backup([]string{dataDir}, main.BackupOpts{Tags: []string{"test"}}) //nolint:exhaustruct
+	err = restic.Backup(t.Context(), []string{dataDir}, main.BackupOpts{Tags: []string{"test"}}) //nolint:exhaustruct
 	AssertEqualFail(t, "unexpected error creating making backup", nil, err)
 
 	// Check snapshots
 	expectedHostname, _ := os.Hostname()
-	snapshots, err := restic.ReadSnapshots()
+	snapshots, err := restic.ReadSnapshots(t.Context())
 	AssertEqualFail(t, "unexpected error reading snapshots", nil, err)
 	AssertEqual(t, "unexpected number of snapshots", 1, len(snapshots))
 
@@ -275,25 +292,25 @@ func TestResticInterface(t *testing.T) {
 	AssertEqual(t, "unexpected snapshot value: tags", []string{"test"}, snapshots[0].Tags)
 
 	// Backup again
-	err = restic.Backup([]string{dataDir}, main.BackupOpts{}) //nolint:exhaustruct
+	err = restic.Backup(t.Context(), []string{dataDir}, main.BackupOpts{}) //nolint:exhaustruct
 	AssertEqualFail(t, "unexpected error creating making second backup", nil, err)
 
 	// Check for second backup
-	snapshots, err = restic.ReadSnapshots()
+	snapshots, err = restic.ReadSnapshots(t.Context())
 	AssertEqualFail(t, "unexpected error reading second snapshots", nil, err)
 	AssertEqual(t, "unexpected number of snapshots", 2, len(snapshots))
 
 	// Forget one backup
-	err = restic.Forget(main.ForgetOpts{KeepLast: 1, Prune: true}) //nolint:exhaustruct
+	err = restic.Forget(t.Context(), main.ForgetOpts{KeepLast: 1, Prune: true}) //nolint:exhaustruct
 	AssertEqualFail(t, "unexpected error forgetting snapshot", nil, err)
 
 	// Check forgotten snapshot
-	snapshots, err = restic.ReadSnapshots()
+	snapshots, err = restic.ReadSnapshots(t.Context())
 	AssertEqualFail(t, "unexpected error reading post forget snapshots", nil, err)
 	AssertEqual(t, "unexpected number of snapshots", 1, len(snapshots))
 
 	// Check restic repo
-	err = restic.Check()
+	err = restic.Check(t.Context(), main.CheckOpts{}) //nolint:exhaustruct
 	AssertEqualFail(t, "unexpected error checking repo", nil, err)
 
 	// Change the data file
@@ -306,7 +323,7 @@ func TestResticInterface(t *testing.T) {
 	AssertEqualFail(t, "incorrect value in test file (we expect the unexpected!)", "unexpected", string(value))
 
 	// Restore files
-	err = restic.Restore("latest", main.RestoreOpts{Target: restoreTarget}) //nolint:exhaustruct
+	err = restic.Restore(t.Context(), "latest", main.RestoreOpts{Target: restoreTarget}) //nolint:exhaustruct
 	AssertEqualFail(t, "unexpected error restoring latest snapshot", nil, err)
 
 	// Check restored values
@@ -315,6 +332,6 @@ func TestResticInterface(t *testing.T) {
 	AssertEqualFail(t, "incorrect value in test file", "testing", string(value))
 
 	// Try to unlock the repo (repo shouldn't really be locked, but this should still run without error
-	err = restic.Unlock(main.UnlockOpts{}) //nolint:exhaustruct
+	err = restic.Unlock(t.Context(), main.UnlockOpts{}) //nolint:exhaustruct
 	AssertEqualFail(t, "unexpected error unlocking repo", nil, err)
 }
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+)
+
+const (
+	defaultRetryMaxAttempts  = 3
+	defaultRetryInitialDelay = 30 * time.Second
+	defaultRetryMultiplier   = 2.0
+)
+
+// RetryErrorClasses maps the error class names accepted by retry { retry_on = [...] } to the errors they match.
+var RetryErrorClasses = map[string]error{
+	"restic":         ErrRestic,
+	"repo_not_found": ErrRepoNotFound,
+	"task":           ErrTask,
+}
+
+// RetryConfig configures retrying a failed job run with exponential backoff.
+type RetryConfig struct {
+	MaxAttempts  int      `hcl:"max_attempts,optional"`
+	InitialDelay string   `hcl:"initial_delay,optional"`
+	Multiplier   float64  `hcl:"multiplier,optional"`
+	MaxDelay     string   `hcl:"max_delay,optional"`
+	RetryOn      []string `hcl:"retry_on,optional"`
+}
+
+// Validate ensures that the retry configuration is valid.
+func (r RetryConfig) Validate() error {
+	if r.MaxAttempts < 0 {
+		return fmt.Errorf("retry max_attempts cannot be negative: %w", ErrInvalidConfigValue)
+	}
+
+	if r.Multiplier != 0 && r.Multiplier < 1 {
+		return fmt.Errorf("retry multiplier must be at least 1: %w", ErrInvalidConfigValue)
+	}
+
+	if err := validateDuration("retry initial_delay", r.InitialDelay); err != nil {
+		return err
+	}
+
+	if err := validateDuration("retry max_delay", r.MaxDelay); err != nil {
+		return err
+	}
+
+	for _, class := range r.RetryOn {
+		if _, ok := RetryErrorClasses[class]; !ok {
+			return fmt.Errorf(
+				"retry retry_on contains unknown error class %q, must be one of %v: %w",
+				class,
+				slices.Sorted(maps.Keys(RetryErrorClasses)),
+				ErrInvalidConfigValue,
+			)
+		}
+	}
+
+	return nil
+}
+
+// Attempts returns the maximum number of times a run will be attempted.
+func (r RetryConfig) Attempts() int {
+	if r.MaxAttempts == 0 {
+		return defaultRetryMaxAttempts
+	}
+
+	return r.MaxAttempts
+}
+
+// Delay returns how long to wait after the provided failed attempt, starting at 1, before trying again.
+func (r RetryConfig) Delay(attempt int) time.Duration {
+	delay := defaultRetryInitialDelay
+	if r.InitialDelay != "" {
+		delay, _ = time.ParseDuration(r.InitialDelay)
+	}
+
+	multiplier := r.Multiplier
+	if multiplier == 0 {
+		multiplier = defaultRetryMultiplier
+	}
+
+	maxDelay := time.Duration(0)
+	if r.MaxDelay != "" {
+		maxDelay, _ = time.ParseDuration(r.MaxDelay)
+	}
+
+	for range attempt - 1 {
+		delay = time.Duration(float64(delay) * multiplier)
+
+		if maxDelay > 0 && delay >= maxDelay {
+			break
+		}
+	}
+
+	if maxDelay > 0 && delay > maxDelay {
+		return maxDelay
+	}
+
+	return delay
+}
+
+// ShouldRetry returns true if a run that failed with err on the provided attempt should be retried.
+// If no error classes are configured, all errors are retried.
+func (r RetryConfig) ShouldRetry(attempt int, err error) bool {
+	if err == nil || attempt >= r.Attempts() {
+		return false
+	}
+
+	if len(r.RetryOn) == 0 {
+		return true
+	}
+
+	for _, class := range r.RetryOn {
+		if errors.Is(err, RetryErrorClasses[class]) {
+			return true
+		}
+	}
+
+	return false
+}
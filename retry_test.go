@@ -0,0 +1,148 @@
+package main_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	main "git.iamthefij.com/iamthefij/restic-scheduler"
+)
+
+func TestRetryConfigValidate(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name        string
+		retry       main.RetryConfig
+		expectedErr error
+	}{
+		{
+			name:        "defaults",
+			retry:       main.RetryConfig{}, //nolint:exhaustruct
+			expectedErr: nil,
+		},
+		{
+			name: "all values",
+			retry: main.RetryConfig{
+				MaxAttempts:  5,
+				InitialDelay: "10s",
+				Multiplier:   3,
+				MaxDelay:     "5m",
+				RetryOn:      []string{"restic", "task"},
+			},
+			expectedErr: nil,
+		},
+		{
+			name:        "invalid delay",
+			retry:       main.RetryConfig{InitialDelay: "soon"}, //nolint:exhaustruct
+			expectedErr: main.ErrInvalidConfigValue,
+		},
+		{
+			name:        "shrinking multiplier",
+			retry:       main.RetryConfig{Multiplier: 0.5}, //nolint:exhaustruct
+			expectedErr: main.ErrInvalidConfigValue,
+		},
+		{
+			name:        "unknown error class",
+			retry:       main.RetryConfig{RetryOn: []string{"validation"}}, //nolint:exhaustruct
+			expectedErr: main.ErrInvalidConfigValue,
+		},
+	}
+
+	for _, c := range cases {
+		testCase := c
+
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			actual := testCase.retry.Validate()
+			if !errors.Is(actual, testCase.expectedErr) {
+				t.Errorf("expected %v but found %v", testCase.expectedErr, actual)
+			}
+		})
+	}
+}
+
+func TestRetryConfigDelay(t *testing.T) {
+	t.Parallel()
+
+	retry := main.RetryConfig{
+		MaxAttempts:  5,
+		InitialDelay: "1s",
+		Multiplier:   2,
+		MaxDelay:     "5s",
+		RetryOn:      nil,
+	}
+
+	actual := []time.Duration{}
+	for attempt := 1; attempt <= 5; attempt++ {
+		actual = append(actual, retry.Delay(attempt))
+	}
+
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+
+	AssertEqual(t, "unexpected retry delays", expected, actual)
+	AssertEqual(t, "unexpected default delay", 30*time.Second, main.RetryConfig{}.Delay(1)) //nolint:exhaustruct
+}
+
+func TestRetryConfigShouldRetry(t *testing.T) {
+	t.Parallel()
+
+	resticErr := fmt.Errorf("failed backing up: %w", main.ErrRestic)
+	taskErr := fmt.Errorf("failed running task: %w", main.ErrTask)
+
+	cases := []struct {
+		name     string
+		retry    main.RetryConfig
+		attempt  int
+		err      error
+		expected bool
+	}{
+		{
+			name:     "no error",
+			retry:    main.RetryConfig{}, //nolint:exhaustruct
+			attempt:  1,
+			err:      nil,
+			expected: false,
+		},
+		{
+			name:     "any error by default",
+			retry:    main.RetryConfig{}, //nolint:exhaustruct
+			attempt:  1,
+			err:      taskErr,
+			expected: true,
+		},
+		{
+			name:     "attempts exhausted",
+			retry:    main.RetryConfig{MaxAttempts: 2}, //nolint:exhaustruct
+			attempt:  2,
+			err:      resticErr,
+			expected: false,
+		},
+		{
+			name:     "matching class",
+			retry:    main.RetryConfig{RetryOn: []string{"restic"}}, //nolint:exhaustruct
+			attempt:  1,
+			err:      main.ErrRepoNotFound,
+			expected: true,
+		},
+		{
+			name:     "non-matching class",
+			retry:    main.RetryConfig{RetryOn: []string{"restic"}}, //nolint:exhaustruct
+			attempt:  1,
+			err:      taskErr,
+			expected: false,
+		},
+	}
+
+	for _, c := range cases {
+		testCase := c
+
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			AssertEqual(t, "unexpected retry decision", testCase.expected, testCase.retry.ShouldRetry(testCase.attempt, testCase.err))
+		})
+	}
+}
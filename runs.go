@@ -0,0 +1,275 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	// RunStateFinished is the state of a run that has finished executing.
+	RunStateFinished = "finished"
+	// RunStateDropped is the state of a run that was dropped from the queue before it started
+	// because the scheduler stopped.
+	RunStateDropped = "dropped"
+
+	// maxTrackedRuns is how many of the most recent runs requested with RunNow can be looked up by ID.
+	maxTrackedRuns = 100
+)
+
+var (
+	// ErrInvalidJobType is returned when a run is requested for a job type the job can't run.
+	ErrInvalidJobType = errors.New("invalid job type")
+	// ErrRunNotFound is returned when looking up a run ID that isn't tracked.
+	ErrRunNotFound = errors.New("run not found")
+	// ErrCancelled is the cause of runs cancelled on request.
+	ErrCancelled = errors.New("run cancelled")
+	// ErrStopped is the cause of runs cancelled because the scheduler stopped, such as on SIGINT.
+	ErrStopped = errors.New("scheduler stopped")
+	// ErrNotRunning is returned when cancelling a job that has no queued or running runs.
+	ErrNotRunning = errors.New("job is not running")
+
+	// OnDemandJobTypes are the job types that may be run with RunNow.
+	OnDemandJobTypes = NewSetFrom([]string{
+		JobTypeBackup,
+		JobTypeForget,
+		JobTypeCheck,
+		JobTypeRestoreVerify,
+		JobTypeUnlock,
+	})
+)
+
+// RunStatus describes a queued run. Scheduled runs cancelled while waiting out their jitter or
+// window have no ID.
+type RunStatus struct {
+	ID       string    `json:"id"`
+	JobName  string    `json:"job_name"`
+	JobType  string    `json:"job_type"`
+	State    string    `json:"state"`
+	QueuedAt time.Time `json:"queued_at"`
+	// Result is set once the run has finished.
+	Result *RunRecord `json:"result,omitempty"`
+}
+
+// RunNow queues a run of the job type for the named job. Like scheduled runs, it waits for the
+// job's repository to be free and for a free slot if concurrent jobs are limited. Windows are not
+// applied since the run was explicitly requested. It returns the ID of the queued run.
+func (s *Scheduler) RunNow(name, jobType string) (string, error) {
+	if !OnDemandJobTypes.Contains(jobType) {
+		return "", fmt.Errorf("%w: %s", ErrInvalidJobType, jobType)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrJobNotFound, name)
+	}
+
+	job := entry.job
+
+	switch {
+	case jobType == JobTypeForget && job.Forget == nil:
+		return "", fmt.Errorf("%w: job %s has no forget config", ErrInvalidJobType, name)
+	case jobType == JobTypeRestoreVerify && job.VerifyRestore == nil:
+		return "", fmt.Errorf("%w: job %s has no verify_restore config", ErrInvalidJobType, name)
+	}
+
+	job.Logger().Printf("Queueing %s run on request", jobType)
+
+	run := s.queue.Submit(job, jobType, TriggerManual)
+
+	s.runs[run.ID] = run
+	s.runIDs = append(s.runIDs, run.ID)
+
+	if len(s.runIDs) > maxTrackedRuns {
+		delete(s.runs, s.runIDs[0])
+		s.runIDs = s.runIDs[1:]
+	}
+
+	return run.ID, nil
+}
+
+// RunStatus returns the status of a run requested with RunNow.
+func (s *Scheduler) RunStatus(id string) (RunStatus, error) {
+	s.mu.Lock()
+	run, ok := s.runs[id]
+	s.mu.Unlock()
+
+	if !ok {
+		return RunStatus{}, fmt.Errorf("%w: %s", ErrRunNotFound, id) //nolint:exhaustruct
+	}
+
+	return s.statusOf(run), nil
+}
+
+// Cancel drops the queued runs of the named job, including scheduled runs waiting out their jitter
+// or window, and cancels its running runs. Their restic commands and tasks are terminated as they
+// would be by StopNow. Every dropped or cancelled run is recorded as cancelled, and jobs that depend
+// on it are treated as if it failed. Once running runs have exited, stale locks left in the
+// repository are removed. It returns the status of each dropped or cancelled run.
+func (s *Scheduler) Cancel(name string) ([]RunStatus, error) {
+	cancelled := s.queue.Cancel(name, ErrCancelled)
+
+	s.mu.Lock()
+	_, ok := s.entries[name]
+	waiting := s.waiting[name]
+	delete(s.waiting, name)
+
+	for _, wait := range waiting {
+		close(wait.cancelled)
+	}
+
+	s.mu.Unlock()
+
+	if len(cancelled) == 0 && len(waiting) == 0 {
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrJobNotFound, name)
+		}
+
+		return nil, fmt.Errorf("%w: %s", ErrNotRunning, name)
+	}
+
+	timeout := time.After(KillGracePeriod + terminateWaitMargin)
+
+	for _, run := range cancelled {
+		select {
+		case <-run.Done():
+		case <-timeout:
+			run.Job.Logger().Printf("Run did not exit after being cancelled")
+		}
+	}
+
+	started := []*QueuedRun{}
+	statuses := make([]RunStatus, 0, len(cancelled)+len(waiting))
+
+	for _, run := range cancelled {
+		if !run.StartedAt.IsZero() {
+			started = append(started, run)
+		} else {
+			s.recordCancelled(run.Job, *run.result)
+		}
+
+		statuses = append(statuses, s.statusOf(run))
+	}
+
+	unlockStaleLocks(started)
+
+	for _, wait := range waiting {
+		info := RunInfo{QueueWait: 0, Trigger: wait.trigger, JobType: wait.jobType, RunID: ""}
+		result := wait.job.cancelledResult(info, ErrCancelled)
+		s.recordCancelled(wait.job, result)
+
+		record := NewRunRecord(result)
+		statuses = append(statuses, RunStatus{
+			ID:       "",
+			JobName:  name,
+			JobType:  wait.jobType,
+			State:    RunStateFinished,
+			QueuedAt: wait.since,
+			Result:   &record,
+		})
+	}
+
+	return statuses, nil
+}
+
+// recordCancelled records the result of a run that was cancelled before it started. Jobs that
+// depend on a cancelled backup are treated as if it failed.
+func (s *Scheduler) recordCancelled(job Job, result JobResult) {
+	JobComplete(result)
+
+	if result.JobType == JobTypeBackup {
+		s.upstreamCompleted(job, false)
+	}
+}
+
+// statusOf returns the status of a queued run.
+func (s *Scheduler) statusOf(run *QueuedRun) RunStatus {
+	status := RunStatus{
+		ID:       run.ID,
+		JobName:  run.Job.Name,
+		JobType:  run.JobType,
+		State:    s.queue.State(run),
+		QueuedAt: run.QueuedAt,
+		Result:   nil,
+	}
+
+	if status.State == RunStateFinished {
+		record := NewRunRecord(*run.result)
+		status.Result = &record
+	}
+
+	return status
+}
+
+// RunJobHandleFunc queues a run of the job named in the path. The `type` query parameter selects
+// the operation and defaults to backup. It responds with the status of the queued run.
+func RunJobHandleFunc(writer http.ResponseWriter, request *http.Request, sched *Scheduler) {
+	jobType := request.URL.Query().Get("type")
+	if jobType == "" {
+		jobType = JobTypeBackup
+	}
+
+	id, err := sched.RunNow(request.PathValue("name"), jobType)
+
+	switch {
+	case errors.Is(err, ErrJobNotFound):
+		http.Error(writer, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writer.Header().Set("Location", "/runs/"+id)
+	writeRunStatus(writer, sched, id, http.StatusAccepted)
+}
+
+// CancelHandleFunc cancels the queued and running runs of the job named in the path. It responds
+// with the status of each cancelled run once they have exited.
+func CancelHandleFunc(writer http.ResponseWriter, request *http.Request, sched *Scheduler) {
+	statuses, err := sched.Cancel(request.PathValue("name"))
+
+	switch {
+	case errors.Is(err, ErrJobNotFound):
+		http.Error(writer, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, ErrNotRunning):
+		http.Error(writer, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(writer).Encode(statuses); err != nil {
+		http.Error(writer, "failed writing json for cancelled runs", http.StatusInternalServerError)
+	}
+}
+
+// RunStatusHandleFunc responds with the status of the run with the ID in the path.
+func RunStatusHandleFunc(writer http.ResponseWriter, request *http.Request, sched *Scheduler) {
+	writeRunStatus(writer, sched, request.PathValue("id"), http.StatusOK)
+}
+
+// writeRunStatus writes the status of the run as JSON with the provided status code.
+func writeRunStatus(writer http.ResponseWriter, sched *Scheduler, id string, code int) {
+	status, err := sched.RunStatus(id)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(code)
+
+	if err := json.NewEncoder(writer).Encode(status); err != nil {
+		http.Error(writer, "failed writing json for run", http.StatusInternalServerError)
+	}
+}
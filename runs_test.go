@@ -0,0 +1,323 @@
+package main_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	main "git.iamthefij.com/iamthefij/restic-scheduler"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchedulerRunNow(t *testing.T) {
+	t.Parallel()
+
+	sched := main.NewScheduler()
+
+	err := sched.Start([]main.Job{
+		{Name: "TestRunNowJob", Schedule: "@daily"}, //nolint:exhaustruct
+	})
+	AssertEqualFail(t, "unexpected error starting scheduler", nil, err)
+
+	t.Cleanup(func() { sched.StopGraceful(0) })
+
+	cases := []struct {
+		name        string
+		jobName     string
+		jobType     string
+		expectedErr error
+	}{
+		{name: "Unknown job", jobName: "missing", jobType: main.JobTypeBackup, expectedErr: main.ErrJobNotFound},
+		{name: "Unknown type", jobName: "TestRunNowJob", jobType: "restore", expectedErr: main.ErrInvalidJobType},
+		{name: "No forget config", jobName: "TestRunNowJob", jobType: main.JobTypeForget, expectedErr: main.ErrInvalidJobType},
+		{
+			name:        "No verify_restore config",
+			jobName:     "TestRunNowJob",
+			jobType:     main.JobTypeRestoreVerify,
+			expectedErr: main.ErrInvalidJobType,
+		},
+	}
+
+	for _, c := range cases {
+		testCase := c
+
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := sched.RunNow(testCase.jobName, testCase.jobType)
+			if !errors.Is(err, testCase.expectedErr) {
+				t.Errorf("expected %v but found %v", testCase.expectedErr, err)
+			}
+		})
+	}
+
+	_, err = sched.RunStatus("missing")
+	if !errors.Is(err, main.ErrRunNotFound) {
+		t.Errorf("expected %v but found %v", main.ErrRunNotFound, err)
+	}
+}
+
+func TestRunJobHandleFunc(t *testing.T) {
+	t.Parallel()
+
+	sched := main.NewScheduler()
+
+	err := sched.Start([]main.Job{
+		{ //nolint:exhaustruct
+			Name:     "TestRunJobHandler",
+			Schedule: "@daily",
+			Config:   &main.ResticConfig{Repo: t.TempDir()}, //nolint:exhaustruct
+		},
+	})
+	AssertEqualFail(t, "unexpected error starting scheduler", nil, err)
+
+	defer sched.StopGraceful(time.Minute)
+
+	// Unknown jobs are not found
+	req := httptest.NewRequest(http.MethodPost, "/jobs/missing/run", nil)
+	req.SetPathValue("name", "missing")
+
+	rr := httptest.NewRecorder()
+	main.RunJobHandleFunc(rr, req, sched)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	// Unlock the repo. Whether or not restic is installed, the run should finish.
+	req = httptest.NewRequest(http.MethodPost, "/jobs/TestRunJobHandler/run?type=unlock", nil)
+	req.SetPathValue("name", "TestRunJobHandler")
+
+	rr = httptest.NewRecorder()
+	main.RunJobHandleFunc(rr, req, sched)
+	assert.Equal(t, http.StatusAccepted, rr.Code)
+
+	var status main.RunStatus
+
+	err = json.NewDecoder(rr.Body).Decode(&status)
+	AssertEqualFail(t, "unexpected error decoding run status", nil, err)
+	assert.Equal(t, "/runs/"+status.ID, rr.Header().Get("Location"))
+	assert.Equal(t, main.JobTypeUnlock, status.JobType)
+
+	// Poll until the run has finished
+	assert.Eventually(t, func() bool {
+		req := httptest.NewRequest(http.MethodGet, "/runs/"+status.ID, nil)
+		req.SetPathValue("id", status.ID)
+
+		rr := httptest.NewRecorder()
+		main.RunStatusHandleFunc(rr, req, sched)
+
+		if err := json.NewDecoder(rr.Body).Decode(&status); err != nil {
+			return false
+		}
+
+		return status.State == main.RunStateFinished
+	}, 30*time.Second, 10*time.Millisecond)
+
+	if assert.NotNil(t, status.Result) {
+		assert.Equal(t, status.ID, status.Result.RunID)
+		assert.Equal(t, main.TriggerManual, status.Result.Trigger)
+	}
+}
+
+// TestSchedulerCancel replaces restic with a script that hangs, so it can't run in parallel.
+func TestSchedulerCancel(t *testing.T) {
+	binDir := t.TempDir()
+	script := "#!/bin/sh\nfor arg; do [ \"$arg\" = unlock ] && exit 0; done\nexec sleep 60\n"
+
+	err := os.WriteFile(filepath.Join(binDir, "restic"), []byte(script), 0o755)
+	AssertEqualFail(t, "unexpected error writing fake restic", nil, err)
+
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	sched := main.NewScheduler()
+
+	err = sched.Start([]main.Job{
+		{ //nolint:exhaustruct
+			Name:     "TestCancelJob",
+			Schedule: "@daily",
+			Config:   &main.ResticConfig{Repo: t.TempDir()}, //nolint:exhaustruct
+		},
+	})
+	AssertEqualFail(t, "unexpected error starting scheduler", nil, err)
+
+	defer sched.StopGraceful(time.Minute)
+
+	_, err = sched.Cancel("TestCancelJob")
+	if !errors.Is(err, main.ErrNotRunning) {
+		t.Errorf("expected %v but found %v", main.ErrNotRunning, err)
+	}
+
+	_, err = sched.Cancel("missing")
+	if !errors.Is(err, main.ErrJobNotFound) {
+		t.Errorf("expected %v but found %v", main.ErrJobNotFound, err)
+	}
+
+	id, err := sched.RunNow("TestCancelJob", main.JobTypeCheck)
+	AssertEqualFail(t, "unexpected error queueing run", nil, err)
+
+	assert.Eventually(t, func() bool {
+		status, err := sched.RunStatus(id)
+
+		return err == nil && status.State == main.JobStateRunning
+	}, 10*time.Second, 10*time.Millisecond)
+
+	statuses, err := sched.Cancel("TestCancelJob")
+	AssertEqualFail(t, "unexpected error cancelling job", nil, err)
+	AssertEqualFail(t, "unexpected number of cancelled runs", 1, len(statuses))
+
+	assert.Equal(t, id, statuses[0].ID)
+	assert.Equal(t, main.RunStateFinished, statuses[0].State)
+
+	if assert.NotNil(t, statuses[0].Result) {
+		assert.Equal(t, main.JobStatusCancelled, statuses[0].Result.Status)
+	}
+}
+
+// TestSchedulerCancelPending replaces restic and records history, so it can't run in parallel.
+func TestSchedulerCancelPending(t *testing.T) {
+	fakeRestic(t, "case \"$*\" in\n"+
+		"*unlock*) ;;\n"+
+		"*snapshots*) echo '[]' ;;\n"+
+		"*) exec sleep 60 ;;\n"+
+		"esac\n")
+
+	store := recordHistory(t)
+
+	// A window that starts in a couple of minutes so that runs now are deferred
+	start := time.Now().Truncate(time.Minute).Add(2 * time.Minute)
+	window := main.TimeWindow{Days: nil, Start: start.Format("15:04"), End: start.Add(time.Minute).Format("15:04")}
+
+	queuedJob := main.Job{ //nolint:exhaustruct
+		Name:     uniqueJobName("TestCancelQueued"),
+		Schedule: "@daily",
+		Config:   &main.ResticConfig{Passphrase: "shh", Repo: "/repo/queued"}, //nolint:exhaustruct
+	}
+	deferredJob := main.Job{ //nolint:exhaustruct
+		Name:           uniqueJobName("TestCancelDeferred"),
+		Schedule:       "@daily",
+		CatchUp:        true,
+		Config:         &main.ResticConfig{Passphrase: "shh", Repo: "/repo/deferred"}, //nolint:exhaustruct
+		AllowedWindows: []main.TimeWindow{window},
+		WindowPolicy:   main.WindowPolicyDefer,
+	}
+
+	sched := main.NewScheduler()
+
+	err := sched.Start([]main.Job{queuedJob, deferredJob})
+	AssertEqualFail(t, "unexpected error starting scheduler", nil, err)
+
+	defer sched.StopGraceful(time.Minute)
+
+	// Runs queued behind a running run are recorded as cancelled
+	running, err := sched.RunNow(queuedJob.Name, main.JobTypeCheck)
+	AssertEqualFail(t, "unexpected error queueing run", nil, err)
+
+	queued, err := sched.RunNow(queuedJob.Name, main.JobTypeBackup)
+	AssertEqualFail(t, "unexpected error queueing run", nil, err)
+
+	assert.Eventually(t, func() bool {
+		status, err := sched.RunStatus(running)
+
+		return err == nil && status.State == main.JobStateRunning
+	}, 10*time.Second, 10*time.Millisecond)
+
+	statuses, err := sched.Cancel(queuedJob.Name)
+	AssertEqualFail(t, "unexpected error cancelling job", nil, err)
+	AssertEqualFail(t, "unexpected number of cancelled runs", 2, len(statuses))
+
+	for _, status := range statuses {
+		assert.Equal(t, main.RunStateFinished, status.State)
+
+		if assert.NotNil(t, status.Result) {
+			assert.Equal(t, main.JobStatusCancelled, status.Result.Status)
+		}
+	}
+
+	status, err := sched.RunStatus(queued)
+	AssertEqualFail(t, "unexpected error getting run status", nil, err)
+	assert.Equal(t, main.RunStateFinished, status.State)
+
+	if assert.NotNil(t, status.Result) {
+		assert.Equal(t, main.JobStatusCancelled, status.Result.Status)
+		assert.Equal(t, queued, status.Result.RunID)
+	}
+
+	records := waitForHistory(t, store, queuedJob.Name, 2)
+	for _, record := range records {
+		assert.Equal(t, main.JobStatusCancelled, record.Status)
+	}
+
+	// Runs deferred until the job's window are cancelled too
+	assert.True(t, sched.CatchUp(deferredJob, time.Now().Add(-48*time.Hour)))
+
+	assert.Eventually(t, func() bool {
+		return sched.Active().Jobs[deferredJob.Name].State == main.JobStateDeferred
+	}, 10*time.Second, 10*time.Millisecond)
+
+	statuses, err = sched.Cancel(deferredJob.Name)
+	AssertEqualFail(t, "unexpected error cancelling job", nil, err)
+	AssertEqualFail(t, "unexpected number of cancelled runs", 1, len(statuses))
+
+	assert.Equal(t, main.JobTypeBackup, statuses[0].JobType)
+	assert.Equal(t, main.RunStateFinished, statuses[0].State)
+
+	if assert.NotNil(t, statuses[0].Result) {
+		assert.Equal(t, main.JobStatusCancelled, statuses[0].Result.Status)
+		assert.Equal(t, main.TriggerCatchUp, statuses[0].Result.Trigger)
+	}
+
+	assert.Eventually(t, func() bool {
+		return len(sched.Active().DeferredJobs) == 0
+	}, 10*time.Second, 10*time.Millisecond)
+
+	records = waitForHistory(t, store, deferredJob.Name, 1)
+	AssertEqual(t, "unexpected status", main.JobStatusCancelled, records[0].Status)
+
+	_, err = sched.Cancel(deferredJob.Name)
+	if !errors.Is(err, main.ErrNotRunning) {
+		t.Errorf("expected %v but found %v", main.ErrNotRunning, err)
+	}
+}
+
+// TestRunNowOutsideWindow replaces restic with a script that takes a moment, so it can't run in
+// parallel.
+func TestRunNowOutsideWindow(t *testing.T) {
+	fakeRestic(t, "sleep 0.2\n")
+
+	// A window that started an hour ago and ended a minute later
+	start := time.Now().Add(-time.Hour)
+	window := main.TimeWindow{Days: nil, Start: start.Format("15:04"), End: start.Add(time.Minute).Format("15:04")}
+
+	job := main.Job{ //nolint:exhaustruct
+		Name:              uniqueJobName("TestRunNowOutsideWindow"),
+		Schedule:          "@daily",
+		Config:            &main.ResticConfig{Passphrase: "shh", Repo: t.TempDir()}, //nolint:exhaustruct
+		AllowedWindows:    []main.TimeWindow{window},
+		CancelAtWindowEnd: true,
+	}
+
+	sched := main.NewScheduler()
+
+	err := sched.Start([]main.Job{job})
+	AssertEqualFail(t, "unexpected error starting scheduler", nil, err)
+
+	defer sched.StopGraceful(time.Minute)
+
+	id, err := sched.RunNow(job.Name, main.JobTypeCheck)
+	AssertEqualFail(t, "unexpected error queueing run", nil, err)
+
+	var status main.RunStatus
+
+	assert.Eventually(t, func() bool {
+		status, err = sched.RunStatus(id)
+
+		return err == nil && status.State == main.RunStateFinished
+	}, 10*time.Second, 10*time.Millisecond)
+
+	if assert.NotNil(t, status.Result) {
+		assert.Equal(t, main.JobStatusSuccess, status.Result.Status)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+)
+
+// scheduleFieldRanges are the values H may choose from for each field of a standard cron schedule.
+// Like Jenkins, day of month is limited to 28 so that the chosen day exists in every month.
+var scheduleFieldRanges = [5][2]int{
+	{0, 59}, // minute
+	{0, 23}, // hour
+	{1, 28}, // day of month
+	{1, 12}, // month
+	{0, 6},  // day of week
+}
+
+// ExpandHashSchedule replaces Jenkins style H tokens in a standard five field cron schedule with
+// stable values derived from a hash of seed. Each field may use H for a single value, H(a-b) to
+// choose from a range and H/n or H(a-b)/n for a step with a hashed offset. Schedules without an H
+// token, such as descriptors like @daily, are returned unchanged. A CRON_TZ= or TZ= prefix is kept.
+func ExpandHashSchedule(schedule, seed string) (string, error) {
+	prefix, spec := "", schedule
+	if strings.HasPrefix(schedule, "CRON_TZ=") || strings.HasPrefix(schedule, "TZ=") {
+		prefix, spec, _ = strings.Cut(schedule, " ")
+		prefix += " "
+	}
+
+	fields := strings.Fields(spec)
+	if !strings.Contains(spec, "H") || len(fields) != len(scheduleFieldRanges) {
+		return schedule, nil
+	}
+
+	for i, field := range fields {
+		parts := strings.Split(field, ",")
+
+		for j, part := range parts {
+			if !strings.HasPrefix(part, "H") {
+				continue
+			}
+
+			expanded, err := expandHashToken(part, scheduleFieldRanges[i], scheduleHash(seed, i))
+			if err != nil {
+				return "", fmt.Errorf("invalid hash token %q in schedule %q: %w", part, schedule, err)
+			}
+
+			parts[j] = expanded
+		}
+
+		fields[i] = strings.Join(parts, ",")
+	}
+
+	return prefix + strings.Join(fields, " "), nil
+}
+
+// scheduleHash returns a stable hash of seed for a single schedule field. The field index is
+// included so that, for example, the minute and hour chosen for a seed are independent.
+func scheduleHash(seed string, field int) int {
+	hash := fnv.New32a()
+	_, _ = fmt.Fprintf(hash, "%s/%d", seed, field)
+
+	return int(hash.Sum32() & 0x7fffffff)
+}
+
+// expandHashToken expands a single H, H(a-b), H/n or H(a-b)/n token within the provided field bounds.
+func expandHashToken(token string, bounds [2]int, hash int) (string, error) {
+	low, high := bounds[0], bounds[1]
+	rest := strings.TrimPrefix(token, "H")
+
+	if strings.HasPrefix(rest, "(") {
+		end := strings.Index(rest, ")")
+		if end < 0 {
+			return "", fmt.Errorf("missing closing parenthesis: %w", ErrInvalidConfigValue)
+		}
+
+		var err error
+
+		low, high, err = parseHashRange(rest[1:end], bounds)
+		if err != nil {
+			return "", err
+		}
+
+		rest = rest[end+1:]
+	}
+
+	switch {
+	case rest == "":
+		return strconv.Itoa(low + hash%(high-low+1)), nil
+	case strings.HasPrefix(rest, "/"):
+		step, err := strconv.Atoi(rest[1:])
+		if err != nil || step < 1 {
+			return "", fmt.Errorf("invalid step %q: %w", rest[1:], ErrInvalidConfigValue)
+		}
+
+		start := low + hash%min(step, high-low+1)
+
+		return fmt.Sprintf("%d-%d/%d", start, high, step), nil
+	default:
+		return "", fmt.Errorf("unexpected %q after H: %w", rest, ErrInvalidConfigValue)
+	}
+}
+
+// parseHashRange parses the a-b range of an H(a-b) token and ensures it is within bounds.
+func parseHashRange(value string, bounds [2]int) (int, int, error) {
+	lowStr, highStr, found := strings.Cut(value, "-")
+	if !found {
+		return 0, 0, fmt.Errorf("range %q must be in the form a-b: %w", value, ErrInvalidConfigValue)
+	}
+
+	low, lowErr := strconv.Atoi(lowStr)
+	high, highErr := strconv.Atoi(highStr)
+
+	if lowErr != nil || highErr != nil || low > high || low < bounds[0] || high > bounds[1] {
+		return 0, 0, fmt.Errorf(
+			"range %q must be within %d-%d: %w",
+			value,
+			bounds[0],
+			bounds[1],
+			ErrInvalidConfigValue,
+		)
+	}
+
+	return low, high, nil
+}
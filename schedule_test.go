@@ -0,0 +1,191 @@
+package main_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	main "git.iamthefij.com/iamthefij/restic-scheduler"
+	"github.com/robfig/cron/v3"
+)
+
+func TestExpandHashSchedule(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name        string
+		schedule    string
+		check       func(t *testing.T, fields []string)
+		expectedErr error
+	}{
+		{
+			name:     "no hash",
+			schedule: "0 3 * * *",
+			check: func(t *testing.T, fields []string) {
+				t.Helper()
+				AssertEqual(t, "schedule should be unchanged", []string{"0", "3", "*", "*", "*"}, fields)
+			},
+			expectedErr: nil,
+		},
+		{
+			name:     "descriptor",
+			schedule: "@daily",
+			check: func(t *testing.T, fields []string) {
+				t.Helper()
+				AssertEqual(t, "schedule should be unchanged", []string{"@daily"}, fields)
+			},
+			expectedErr: nil,
+		},
+		{
+			name:     "minute and hour ranges",
+			schedule: "H H(1-5) * * *",
+			check: func(t *testing.T, fields []string) {
+				t.Helper()
+				assertFieldInRange(t, fields[0], 0, 59)
+				assertFieldInRange(t, fields[1], 1, 5)
+			},
+			expectedErr: nil,
+		},
+		{
+			name:     "step",
+			schedule: "H/15 * * * *",
+			check: func(t *testing.T, fields []string) {
+				t.Helper()
+
+				start, rest, _ := strings.Cut(fields[0], "-")
+				assertFieldInRange(t, start, 0, 14)
+				AssertEqual(t, "unexpected step", "59/15", rest)
+			},
+			expectedErr: nil,
+		},
+		{
+			name:     "time zone prefix",
+			schedule: "CRON_TZ=Asia/Ho_Chi_Minh H 3 * * *",
+			check: func(t *testing.T, fields []string) {
+				t.Helper()
+				AssertEqual(t, "prefix should be kept", "CRON_TZ=Asia/Ho_Chi_Minh", fields[0])
+				assertFieldInRange(t, fields[1], 0, 59)
+				AssertEqual(t, "unexpected fields", []string{"3", "*", "*", "*"}, fields[2:])
+			},
+			expectedErr: nil,
+		},
+		{
+			name:        "range out of bounds",
+			schedule:    "H(0-90) * * * *",
+			check:       nil,
+			expectedErr: main.ErrInvalidConfigValue,
+		},
+		{
+			name:        "unexpected suffix",
+			schedule:    "Hx * * * *",
+			check:       nil,
+			expectedErr: main.ErrInvalidConfigValue,
+		},
+	}
+
+	for _, c := range cases {
+		testCase := c
+
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			actual, err := main.ExpandHashSchedule(testCase.schedule, "job@host")
+			if !errors.Is(err, testCase.expectedErr) {
+				t.Fatalf("expected %v but found %v", testCase.expectedErr, err)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if _, err := cron.ParseStandard(actual); err != nil {
+				t.Errorf("expanded schedule %q is not valid: %v", actual, err)
+			}
+
+			testCase.check(t, strings.Fields(actual))
+
+			again, _ := main.ExpandHashSchedule(testCase.schedule, "job@host")
+			AssertEqual(t, "expanded schedule should be stable", actual, again)
+		})
+	}
+}
+
+func assertFieldInRange(t *testing.T, field string, low, high int) {
+	t.Helper()
+
+	value, err := strconv.Atoi(field)
+	if err != nil || value < low || value > high {
+		t.Errorf("expected field %q to be a number within %d-%d", field, low, high)
+	}
+}
+
+func TestJobCronScheduleTimezone(t *testing.T) {
+	t.Parallel()
+
+	job := main.Job{ //nolint:exhaustruct
+		Name:     "Test job",
+		Schedule: "30 2 * * *",
+		Timezone: "Europe/Berlin",
+	}
+
+	spec, err := job.CronSchedule()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	AssertEqual(t, "schedule should be prefixed with the timezone", "CRON_TZ=Europe/Berlin 30 2 * * *", spec)
+
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		t.Fatalf("unexpected error parsing %q: %v", spec, err)
+	}
+
+	location := job.Location()
+	AssertEqual(t, "location should match timezone", "Europe/Berlin", location.String())
+
+	// 02:30 does not exist in Berlin on the morning clocks spring forward, so the run should move
+	// to the following day while still being at 02:30 local time
+	next := schedule.Next(time.Date(2026, time.March, 28, 12, 0, 0, 0, time.UTC)).In(location)
+	AssertEqual(t, "next run should be in local time", "2026-03-30 02:30", next.Format("2006-01-02 15:04"))
+
+	next = schedule.Next(time.Date(2026, time.July, 1, 12, 0, 0, 0, time.UTC)).In(location)
+	AssertEqual(t, "next run should be in summer time", "2026-07-02 02:30 CEST", next.Format("2006-01-02 15:04 MST"))
+}
+
+func TestParseConfigTimezone(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "timezone.hcl")
+
+	err := os.WriteFile(path, []byte(`
+job "TestTimezone" {
+  schedule = "30 2 * * *"
+  timezone = "Europe/Berlin"
+
+  config {
+    repo       = "./backups"
+    passphrase = "shh"
+  }
+
+  backup {
+    paths = ["./data"]
+  }
+}
+`), 0o600)
+	AssertEqualFail(t, "unexpected error writing config", nil, err)
+
+	config, err := main.ParseConfig(path)
+	AssertEqualFail(t, "unexpected error parsing config", nil, err)
+	AssertEqual(t, "location should match timezone", "Europe/Berlin", config.Jobs[0].Location().String())
+
+	// Loading the same time zone again doesn't make the job look changed
+	reloaded, err := main.ParseConfig(path)
+	AssertEqualFail(t, "unexpected error parsing config", nil, err)
+
+	changes := main.DiffJobs(config.Jobs, reloaded.Jobs)
+	AssertEqual(t, "unexpected unchanged jobs", []string{"TestTimezone"}, changes.Unchanged)
+}
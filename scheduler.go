@@ -1,22 +1,83 @@
 package main
 
 import (
+	"cmp"
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
+	"maps"
+	"math/rand/v2"
 	"net/http"
+	"reflect"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/robfig/cron/v3"
 )
 
+const (
+	// JobStatusSuccess is the status of a run that completed successfully.
+	JobStatusSuccess = "success"
+	// JobStatusFailure is the status of a run that failed.
+	JobStatusFailure = "failure"
+	// JobStatusTimeout is the status of a run that failed because it exceeded its timeout.
+	JobStatusTimeout = "timeout"
+	// JobStatusSkipped is the status of a scheduled run that was not started.
+	JobStatusSkipped = "skipped"
+	// JobStatusCancelled is the status of a run that was stopped before it finished, such as when its window ended.
+	JobStatusCancelled = "cancelled"
+
+	// terminateWaitMargin is how much longer than KillGracePeriod to wait for terminated jobs to exit.
+	terminateWaitMargin = 5 * time.Second
+	// unlockTimeout limits how long unlocking a repository after terminating a job may take.
+	unlockTimeout = time.Minute
+	// httpReadHeaderTimeout limits how long clients of the HTTPS server may take to send headers.
+	httpReadHeaderTimeout = 10 * time.Second
+
+	// TriggerSchedule is the trigger of a run started by the job's schedule.
+	TriggerSchedule = "schedule"
+	// TriggerCatchUp is the trigger of a run started to make up for a run missed while the scheduler was down.
+	TriggerCatchUp = "catch-up"
+	// TriggerUpstream is the trigger of a run started because the jobs it depends on completed.
+	TriggerUpstream = "upstream"
+	// TriggerManual is the trigger of a run started on request, such as with the -backup flag.
+	TriggerManual = "manual"
+
+	// JobStateIdle is the state of a job that isn't running or waiting to run.
+	JobStateIdle = "idle"
+	// JobStateRunning is the state of a job with a run in progress.
+	JobStateRunning = "running"
+	// JobStateQueued is the state of a job with a run waiting for its repository or a free slot.
+	JobStateQueued = "queued"
+	// JobStateDeferred is the state of a job with a run waiting for its window to start.
+	JobStateDeferred = "deferred"
+
+	// SkipReasonOverlap is the reason recorded when a run is skipped because the previous run is still in progress.
+	SkipReasonOverlap = "overlap"
+)
+
 // In-memory job result storage (shared across scheduler instances)
 var (
 	jobResultsLock = sync.Mutex{}
-	jobResults     = map[string]JobResult{}
+	jobResults     = map[jobResultKey]JobResult{}
+)
+
+// Name of the task each running job is currently on
+var (
+	currentTasksLock = sync.Mutex{}
+	currentTasks     = map[string]string{}
 )
 
+// jobResultKey identifies the last result of a job type for a job.
+type jobResultKey struct {
+	jobName string
+	jobType string
+}
+
 // Scheduler manages a cron instance and a set of scheduled jobs.
 type Scheduler struct {
 	mu       sync.Mutex
@@ -24,11 +85,382 @@ type Scheduler struct {
 	jobs     []Job
 	jobNames []string
 	started  bool
+	entries  map[string]*jobEntry
+	queue    *RunQueue
+	deferred map[string]deferral
+
+	// waiting maps a job name to its scheduled runs that are waiting out their jitter or for the
+	// job's window to start, so they can be cancelled
+	waiting map[string][]*waitingRun
+
+	// downstream maps a job name to the jobs that depend on it
+	downstream map[string][]Job
+	// upstreamResults maps a dependent job name to whether each job it depends on has succeeded
+	// since it last ran
+	upstreamResults map[string]map[string]bool
+
+	// runs are the most recent runs requested with RunNow by ID, and runIDs their IDs in the order
+	// they were requested
+	runs   map[string]*QueuedRun
+	runIDs []string
+
+	// detached counts runs started with submitDetached that have not yet been submitted to the
+	// queue, such as catch-up runs deferred until the job's window starts
+	detached sync.WaitGroup
+
+	// paused are the names of jobs paused individually and pausedAll is set if all jobs are paused.
+	// If pauseFile is set, changes are saved to it.
+	paused    Set
+	pausedAll bool
+	pauseFile string
+}
+
+// jobEntry is a scheduled job. Jobs have a cron entry, keyed by job type, for each of their
+// backup, forget and check schedules. Jobs that depend on other jobs have no backup entry. The
+// stopped channel is closed when the job is unscheduled.
+type jobEntry struct {
+	job     Job
+	ids     map[string]cron.EntryID
+	stopped chan struct{}
+}
+
+// deferral tracks the runs of a job that are waiting for the job's window to start.
+type deferral struct {
+	count int
+	until time.Time
+}
+
+// waitingRun is a scheduled run waiting out its jitter or for its job's window to start. The
+// cancelled channel is closed if the run is cancelled while waiting.
+type waitingRun struct {
+	job       Job
+	jobType   string
+	trigger   string
+	since     time.Time
+	cancelled chan struct{}
 }
 
 // NewScheduler constructs an empty Scheduler.
 func NewScheduler() *Scheduler {
-	return &Scheduler{}
+	s := &Scheduler{
+		mu:              sync.Mutex{},
+		cron:            nil,
+		jobs:            nil,
+		jobNames:        nil,
+		started:         false,
+		entries:         map[string]*jobEntry{},
+		queue:           nil,
+		deferred:        map[string]deferral{},
+		waiting:         map[string][]*waitingRun{},
+		downstream:      map[string][]Job{},
+		upstreamResults: map[string]map[string]bool{},
+		runs:            map[string]*QueuedRun{},
+		runIDs:          []string{},
+		detached:        sync.WaitGroup{},
+		paused:          Set{},
+		pausedAll:       false,
+		pauseFile:       "",
+	}
+	s.queue = NewRunQueue(s.execute)
+
+	return s
+}
+
+// execute runs a job from the queue and then triggers any jobs that depend on it. Runs may wait in
+// the queue until after their job's window has ended, so runs found outside of the window are
+// deferred or skipped again rather than started. Manual runs are neither held to the job's window
+// nor cancelled at its end since they were explicitly requested.
+func (s *Scheduler) execute(ctx context.Context, run *QueuedRun) {
+	if run.Trigger != TriggerManual && !run.Job.InWindow(time.Now()) {
+		s.rewaitForWindow(run)
+
+		return
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	if run.Trigger != TriggerManual {
+		runCtx, cancel = run.Job.withWindowDeadline(ctx)
+	}
+	defer cancel()
+
+	result := run.Job.RunWithInfo(runCtx, RunInfo{
+		QueueWait: run.QueueWait(),
+		Trigger:   run.Trigger,
+		JobType:   run.JobType,
+		RunID:     run.ID,
+	})
+	run.result = &result
+
+	if run.JobType == JobTypeBackup {
+		s.upstreamCompleted(run.Job, result.Success)
+	}
+}
+
+// rewaitForWindow handles a run that left the queue outside of its job's window. Like a scheduled
+// run, it is deferred until the next window starts and queued again, or skipped, depending on the
+// job's window policy. Runs of jobs that were removed or after the scheduler stopped are dropped.
+func (s *Scheduler) rewaitForWindow(run *QueuedRun) {
+	run.Job.Logger().Printf("Window ended while the %s run was queued", run.JobType)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[run.Job.Name]
+	if !s.started || !ok {
+		run.Job.Logger().Printf("Dropping run outside of window")
+
+		return
+	}
+
+	scheduledJob{
+		job:       run.Job,
+		jobType:   run.JobType,
+		scheduler: s,
+		stopped:   entry.stopped,
+		trigger:   run.Trigger,
+	}.submitDetached()
+}
+
+// scheduledJob is the cron entry for a Job. Rather than running the job directly, it submits
+// a run to the scheduler queue and blocks until that run has completed.
+type scheduledJob struct {
+	job       Job
+	jobType   string
+	scheduler *Scheduler
+	stopped   <-chan struct{}
+	trigger   string
+}
+
+// Run waits for a random jitter and the job's window, if configured, then submits the job to the
+// queue and waits for it to finish. Runs of paused jobs are skipped.
+func (sj scheduledJob) Run() {
+	if sj.skipIfPaused() {
+		return
+	}
+
+	// The job may have been paused while waiting
+	if !sj.waitToStart(true) || sj.skipIfPaused() {
+		return
+	}
+
+	<-sj.scheduler.queue.Submit(sj.job, sj.jobType, sj.trigger).Done()
+}
+
+// waitToStart waits out a random jitter, if enabled, and for the job's window. While waiting, the
+// run can be cancelled with Scheduler.Cancel. It returns false if the run was skipped, cancelled or
+// the scheduler stopped while waiting.
+func (sj scheduledJob) waitToStart(jitter bool) bool {
+	wait := sj.scheduler.startWait(sj)
+
+	ready := (!jitter || sj.waitForJitter(wait.cancelled)) && sj.waitForWindow(wait.cancelled)
+
+	if !sj.scheduler.endWait(sj.job.Name, wait) {
+		sj.job.Logger().Printf("Run cancelled before it started")
+
+		return false
+	}
+
+	return ready
+}
+
+// waitForJitter waits for a random delay up to the job's jitter. It returns false if the scheduler
+// stopped or the run was cancelled while waiting.
+func (sj scheduledJob) waitForJitter(cancelled <-chan struct{}) bool {
+	jitter := sj.job.JitterDuration()
+	if jitter <= 0 {
+		return true
+	}
+
+	delay := rand.N(jitter) //nolint:gosec
+
+	sj.job.Logger().Printf("Delaying run by %s of jitter", delay.Round(time.Second))
+
+	select {
+	case <-sj.stopped:
+		sj.job.Logger().Printf("Scheduler stopped before jittered run started")
+
+		return false
+	case <-cancelled:
+		return false
+	case <-time.After(delay):
+		return true
+	}
+}
+
+// submitDetached submits the job to the queue once it is within its window, without waiting for
+// the run to complete. Runs of paused jobs are skipped. The caller must hold the scheduler's mu and
+// have checked that the scheduler is running, so StopGraceful waits for the run.
+func (sj scheduledJob) submitDetached() {
+	sj.scheduler.detached.Add(1)
+
+	go func() {
+		defer sj.scheduler.detached.Done()
+
+		if !sj.skipIfPaused() && sj.waitToStart(false) && !sj.skipIfPaused() {
+			sj.scheduler.queue.Submit(sj.job, sj.jobType, sj.trigger)
+		}
+	}()
+}
+
+// skipIfPaused records a skipped run and returns true if the job is paused.
+func (sj scheduledJob) skipIfPaused() bool {
+	if !sj.scheduler.IsPaused(sj.job.Name) {
+		return false
+	}
+
+	sj.job.RecordSkipped(sj.jobType, SkipReasonPaused)
+
+	return true
+}
+
+// waitForWindow returns true once the job is within its window. Runs outside of the window are
+// either skipped or deferred until the window starts, depending on the job's window policy. It
+// returns false if the run was skipped, or the scheduler stopped or the run was cancelled while the
+// run was deferred.
+func (sj scheduledJob) waitForWindow(cancelled <-chan struct{}) bool {
+	now := time.Now()
+	if sj.job.InWindow(now) {
+		return true
+	}
+
+	if sj.job.WindowPolicy == WindowPolicySkip {
+		sj.job.RecordSkipped(sj.jobType, SkipReasonWindow)
+
+		return false
+	}
+
+	start := sj.job.NextWindowStart(now)
+	if start.IsZero() {
+		sj.job.Logger().Printf("ERROR: Job windows never allow it to run")
+		sj.job.RecordSkipped(sj.jobType, SkipReasonWindow)
+
+		return false
+	}
+
+	sj.job.Logger().Printf("Deferring run until window starts at %s", start.Format(time.RFC3339))
+
+	sj.scheduler.addDeferral(sj.job, start)
+	defer sj.scheduler.removeDeferral(sj.job)
+
+	select {
+	case <-sj.stopped:
+		sj.job.Logger().Printf("Scheduler stopped before deferred run started")
+
+		return false
+	case <-cancelled:
+		return false
+	case <-time.After(time.Until(start)):
+		return true
+	}
+}
+
+// startWait records that a run is waiting to start so that it can be cancelled.
+func (s *Scheduler) startWait(sj scheduledJob) *waitingRun {
+	wait := &waitingRun{
+		job:       sj.job,
+		jobType:   sj.jobType,
+		trigger:   sj.trigger,
+		since:     time.Now(),
+		cancelled: make(chan struct{}),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.waiting[sj.job.Name] = append(s.waiting[sj.job.Name], wait)
+
+	return wait
+}
+
+// endWait records that a run of the named job is no longer waiting to start. It returns false if
+// the run was cancelled while waiting.
+func (s *Scheduler) endWait(jobName string, wait *waitingRun) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	waiting := s.waiting[jobName]
+
+	i := slices.Index(waiting, wait)
+	if i < 0 {
+		return false
+	}
+
+	if waiting = slices.Delete(waiting, i, i+1); len(waiting) == 0 {
+		delete(s.waiting, jobName)
+	} else {
+		s.waiting[jobName] = waiting
+	}
+
+	return true
+}
+
+// addDeferral records that a run of job is waiting until start for the job's window.
+func (s *Scheduler) addDeferral(job Job, start time.Time) {
+	Metrics.JobDeferred.WithLabelValues(job.Name).Inc()
+	Metrics.JobDeferredCount.WithLabelValues(job.Name).Inc()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deferred := s.deferred[job.Name]
+	deferred.count++
+
+	if deferred.until.IsZero() || start.Before(deferred.until) {
+		deferred.until = start
+	}
+
+	s.deferred[job.Name] = deferred
+}
+
+// removeDeferral records that a deferred run of job is no longer waiting.
+func (s *Scheduler) removeDeferral(job Job) {
+	Metrics.JobDeferred.WithLabelValues(job.Name).Dec()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deferred := s.deferred[job.Name]
+
+	deferred.count--
+	if deferred.count <= 0 {
+		delete(s.deferred, job.Name)
+
+		return
+	}
+
+	s.deferred[job.Name] = deferred
+}
+
+// OverlapWrapper returns a cron.JobWrapper implementing the job's overlap policy. Because a
+// scheduledJob blocks until its run completes, a run waiting in the queue counts as still running.
+func OverlapWrapper(job Job) cron.JobWrapper {
+	switch job.Overlap {
+	case OverlapSkip:
+		return skipIfStillRunning(job)
+	case OverlapQueue:
+		return cron.DelayIfStillRunning(cron.VerbosePrintfLogger(job.Logger()))
+	default:
+		return func(j cron.Job) cron.Job { return j }
+	}
+}
+
+// skipIfStillRunning behaves like cron.SkipIfStillRunning, but records each skipped run as a job result.
+func skipIfStillRunning(job Job) cron.JobWrapper {
+	return func(j cron.Job) cron.Job {
+		ready := make(chan struct{}, 1)
+		ready <- struct{}{}
+
+		return cron.FuncJob(func() {
+			select {
+			case token := <-ready:
+				defer func() { ready <- token }()
+
+				j.Run()
+			default:
+				job.RecordSkipped(JobTypeBackup, SkipReasonOverlap)
+			}
+		})
+	}
 }
 
 // Start schedules the provided jobs and starts the internal cron instance.
@@ -42,87 +474,613 @@ func (s *Scheduler) Start(jobs []Job) error {
 		return fmt.Errorf("scheduler already started")
 	}
 
-	c := cron.New()
-	names := make([]string, 0, len(jobs))
-
-	for _, job := range jobs {
-		log.Printf("Scheduling %s", job.Name)
+	if err := validateSchedules(jobs); err != nil {
+		return err
+	}
 
-		if _, err := c.AddJob(job.Schedule, job); err != nil {
-			return fmt.Errorf("error scheduling job %s: %w", job.Name, err)
-		}
+	s.cron = cron.New()
+	s.entries = map[string]*jobEntry{}
 
-		names = append(names, job.Name)
+	for _, job := range jobs {
+		s.addEntryLocked(job)
 	}
 
-	// start the scheduler
-	c.Start()
+	s.setJobsLocked(jobs, Set{})
 
-	s.cron = c
-	s.jobs = jobs
-	s.jobNames = names
+	// start the scheduler
+	s.cron.Start()
 	s.started = true
 
 	return nil
 }
 
-// ReplaceJobs stops the current scheduler (waiting for in-flight jobs to finish)
-// and starts a new scheduler with newJobs. This is a graceful replacement.
+// JobChanges summarizes the difference between two sets of jobs by name.
+type JobChanges struct {
+	Added     []string
+	Changed   []string
+	Removed   []string
+	Unchanged []string
+}
+
+func (c JobChanges) String() string {
+	return fmt.Sprintf(
+		"added %d %v, changed %d %v, removed %d %v, unchanged %d",
+		len(c.Added), c.Added,
+		len(c.Changed), c.Changed,
+		len(c.Removed), c.Removed,
+		len(c.Unchanged),
+	)
+}
+
+// DiffJobs compares jobs by name to find which were added, removed or changed in any way.
+func DiffJobs(oldJobs, newJobs []Job) JobChanges {
+	changes := JobChanges{Added: []string{}, Changed: []string{}, Removed: []string{}, Unchanged: []string{}}
+
+	oldByName := make(map[string]Job, len(oldJobs))
+	for _, job := range oldJobs {
+		oldByName[job.Name] = job
+	}
+
+	newNames := Set{}
+
+	for _, job := range newJobs {
+		newNames[job.Name] = true
+
+		oldJob, ok := oldByName[job.Name]
+
+		// Locations are loaded from the timezone, which is compared instead
+		oldJob.location, job.location = nil, nil
+
+		switch {
+		case !ok:
+			changes.Added = append(changes.Added, job.Name)
+		case reflect.DeepEqual(oldJob, job):
+			changes.Unchanged = append(changes.Unchanged, job.Name)
+		default:
+			changes.Changed = append(changes.Changed, job.Name)
+		}
+	}
+
+	for _, job := range oldJobs {
+		if !newNames.Contains(job.Name) {
+			changes.Removed = append(changes.Removed, job.Name)
+		}
+	}
+
+	return changes
+}
+
+// ReplaceJobs reschedules only the jobs that differ between the current jobs and newJobs. Removed
+// and changed jobs are unscheduled, but any of their runs already in progress are left to finish.
+// New and changed jobs are then scheduled. Unchanged jobs are not touched. If the scheduler is not
+// started, it is started with newJobs.
 func (s *Scheduler) ReplaceJobs(newJobs []Job) error {
-	// Swap out safely: stop old cron and wait for running jobs to finish.
 	s.mu.Lock()
-	// Keep a snapshot of previous jobs for potential fallback (caller may handle)
-	prevJobs := s.jobs
+	started := s.started
 	s.mu.Unlock()
 
-	s.StopGraceful()
+	if !started {
+		return s.Start(newJobs)
+	}
 
-	// Start new scheduler with new jobs.
-	if err := s.Start(newJobs); err != nil {
-		// If starting new scheduler fails, attempt to restart previous jobs if available.
-		if len(prevJobs) > 0 {
-			log.Printf("failed to start new scheduler: %v; attempting to restart previous jobs", err)
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-			if restartErr := s.Start(prevJobs); restartErr != nil {
-				return fmt.Errorf("failed to restart previous scheduler after replace error: %w (original replace err: %w)", restartErr, err)
-			}
+	// Check schedules before making any changes so that a failure leaves the current jobs running
+	if err := validateSchedules(newJobs); err != nil {
+		return fmt.Errorf("replace failed, keeping previous jobs: %w", err)
+	}
+
+	changes := DiffJobs(s.jobs, newJobs)
+	log.Printf("Reloading jobs: %s", changes)
 
-			// Returning original error but scheduler recovered to previous state.
-			return fmt.Errorf("replace failed but previous scheduler restarted: %w", err)
+	for _, name := range slices.Concat(changes.Removed, changes.Changed) {
+		s.removeEntryLocked(name)
+	}
+
+	for _, job := range newJobs {
+		if _, ok := s.entries[job.Name]; !ok {
+			s.addEntryLocked(job)
 		}
+	}
+
+	s.setJobsLocked(newJobs, NewSetFrom(changes.Unchanged))
+
+	return nil
+}
+
+// validateSchedules ensures that the schedules of all provided jobs can be parsed.
+func validateSchedules(jobs []Job) error {
+	for _, job := range jobs {
+		for _, jobType := range ScheduledJobTypes {
+			spec, err := job.CronScheduleFor(jobType)
+			if err != nil {
+				return fmt.Errorf("error scheduling %s of job %s: %w", jobType, job.Name, err)
+			}
 
-		return fmt.Errorf("replace failed and no previous jobs to restart: %w", err)
+			if spec == "" {
+				continue
+			}
+
+			if _, err := cron.ParseStandard(spec); err != nil {
+				return fmt.Errorf("error scheduling %s of job %s: %w", jobType, job.Name, err)
+			}
+		}
 	}
 
 	return nil
 }
 
-// StopNow stops scheduling and returns immediately (does not wait for running jobs to finish).
+// addEntryLocked schedules a job. The job's schedule is expected to have already been validated.
+// The caller must hold s.mu.
+func (s *Scheduler) addEntryLocked(job Job) {
+	entry := &jobEntry{job: job, ids: map[string]cron.EntryID{}, stopped: make(chan struct{})}
+	s.entries[job.Name] = entry
+
+	if len(job.DependsOn) > 0 {
+		log.Printf("Scheduling %s after %s", job.Name, strings.Join(job.DependsOn, ", "))
+	}
+
+	for _, jobType := range ScheduledJobTypes {
+		spec, _ := job.CronScheduleFor(jobType)
+		if spec == "" {
+			continue
+		}
+
+		schedule, _ := cron.ParseStandard(spec)
+
+		var cronJob cron.Job = scheduledJob{
+			job:       job,
+			jobType:   jobType,
+			scheduler: s,
+			stopped:   entry.stopped,
+			trigger:   TriggerSchedule,
+		}
+
+		if jobType == JobTypeBackup {
+			log.Printf("Scheduling %s at %s", job.Name, spec)
+
+			cronJob = cron.NewChain(OverlapWrapper(job)).Then(cronJob)
+		} else {
+			log.Printf("Scheduling %s of %s at %s", jobType, job.Name, spec)
+		}
+
+		entry.ids[jobType] = s.cron.Schedule(schedule, cronJob)
+	}
+}
+
+// removeEntryLocked unschedules a job. Runs already in progress are not interrupted, but runs
+// waiting for their jitter or window are dropped. The caller must hold s.mu.
+func (s *Scheduler) removeEntryLocked(name string) {
+	entry, ok := s.entries[name]
+	if !ok {
+		return
+	}
+
+	for _, id := range entry.ids {
+		s.cron.Remove(id)
+	}
+
+	close(entry.stopped)
+	delete(s.entries, name)
+}
+
+// setJobsLocked records the current set of jobs and rebuilds the dependencies between them. The
+// completed upstream jobs of dependent jobs in keepResults are kept. The caller must hold s.mu.
+func (s *Scheduler) setJobsLocked(jobs []Job, keepResults Set) {
+	names := make([]string, 0, len(jobs))
+	downstream := map[string][]Job{}
+	upstreamResults := map[string]map[string]bool{}
+
+	for _, job := range jobs {
+		names = append(names, job.Name)
+
+		if len(job.DependsOn) == 0 {
+			continue
+		}
+
+		for _, upstream := range job.DependsOn {
+			downstream[upstream] = append(downstream[upstream], job)
+		}
+
+		upstreamResults[job.Name] = map[string]bool{}
+		if results, ok := s.upstreamResults[job.Name]; ok && keepResults.Contains(job.Name) {
+			upstreamResults[job.Name] = results
+		}
+	}
+
+	s.jobs = jobs
+	s.jobNames = names
+	s.downstream = downstream
+	s.upstreamResults = upstreamResults
+
+	s.prunePausedLocked()
+}
+
+// StopNow stops scheduling and terminates any running jobs without waiting for them to finish.
+// Their child processes are sent SIGTERM, then SIGKILL after KillGracePeriod. Stale locks left in
+// the repositories of terminated jobs are then removed.
 func (s *Scheduler) StopNow() {
 	s.mu.Lock()
 	c := s.cron
 	s.cron = nil
 	s.started = false
+	s.closeStoppedLocked()
 	s.mu.Unlock()
 
 	if c != nil {
 		// Stop returns a context that is closed when running jobs finish; we intentionally don't wait here.
 		c.Stop()
 	}
+
+	s.terminateRuns()
 }
 
-// StopGraceful stops the scheduler and waits for any running jobs to finish before returning.
-func (s *Scheduler) StopGraceful() {
+// StopGraceful stops the scheduler and waits for any running or queued jobs to finish before
+// returning. This includes runs requested with RunNow, catch-up runs and runs triggered by jobs
+// they depend on. Runs waiting out their jitter or window are dropped. If timeout is greater than 0
+// and jobs are still running once it passes, they are terminated as they would be by StopNow.
+func (s *Scheduler) StopGraceful(timeout time.Duration) {
 	s.mu.Lock()
 	c := s.cron
 	s.cron = nil
 	s.started = false
+	s.closeStoppedLocked()
 	s.mu.Unlock()
 
-	if c != nil {
-		ctx := c.Stop()
+	if c == nil {
+		return
+	}
+
+	ctx := c.Stop()
+	done := make(chan struct{})
+
+	go func() {
 		<-ctx.Done()
+		s.detached.Wait()
+		s.queue.Drain()
+		close(done)
+	}()
+
+	if timeout <= 0 {
+		<-done
+
+		return
+	}
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		log.Printf("Running jobs did not finish within %s; stopping them now", timeout)
+		s.terminateRuns()
+	}
+}
+
+// closeStoppedLocked notifies scheduled jobs waiting out their jitter or window that the
+// scheduler has stopped. The caller must hold s.mu.
+func (s *Scheduler) closeStoppedLocked() {
+	for _, entry := range s.entries {
+		close(entry.stopped)
+	}
+
+	s.entries = map[string]*jobEntry{}
+}
+
+// terminateRuns drops queued runs, cancels running runs and waits for their processes to exit.
+// Afterwards, the repositories of cancelled runs are unlocked so that the locks left behind by
+// the killed restic processes don't block future runs.
+func (s *Scheduler) terminateRuns() {
+	cancelled := s.queue.Stop(ErrStopped)
+	if len(cancelled) == 0 {
+		return
+	}
+
+	if !s.queue.Wait(KillGracePeriod + terminateWaitMargin) {
+		log.Printf("Some jobs did not exit after being terminated")
+	}
+
+	unlockStaleLocks(cancelled)
+}
+
+// unlockStaleLocks removes the locks left behind in the repositories of terminated runs by their
+// killed restic processes.
+func unlockStaleLocks(cancelled []*QueuedRun) {
+	unlocked := Set{}
+
+	for _, run := range cancelled {
+		if run.Job.Config == nil || unlocked.Contains(run.Job.Config.Repo) {
+			continue
+		}
+
+		unlocked[run.Job.Config.Repo] = true
+
+		ctx, cancel := context.WithTimeout(context.Background(), unlockTimeout)
+
+		run.Job.Logger().Printf("Removing stale locks from repository")
+
+		// Stale locks are those held by processes on this host that are no longer running, so
+		// this won't remove locks held by other hosts.
+		if err := run.Job.NewRestic().Unlock(ctx, UnlockOpts{RemoveAll: false}); err != nil {
+			run.Job.Logger().Printf("ERROR: Failed to unlock repository: %v", err)
+		}
+
+		cancel()
+	}
+}
+
+// CatchUp queues an immediate run of a job with catch_up enabled if it missed a scheduled run since
+// its latest snapshot, such as while the scheduler was not running. If the job is outside of its
+// window, the run is deferred or skipped like a scheduled run would be. It does not wait for the
+// run to complete. It returns true if a catch-up run was started.
+func (s *Scheduler) CatchUp(job Job, latestSnapshot time.Time) bool {
+	if !job.CatchUp || !job.MissedRun(latestSnapshot, time.Now()) {
+		return false
+	}
+
+	if slices.Contains(s.queue.RunningJobNames(), job.Name) || slices.Contains(s.queue.QueuedJobNames(), job.Name) {
+		job.Logger().Printf("Missed a scheduled run, but a run is already in progress")
+
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[job.Name]
+	if !ok {
+		return false
+	}
+
+	if len(s.waiting[job.Name]) > 0 {
+		job.Logger().Printf("Missed a scheduled run, but a run is already waiting to start")
+
+		return false
+	}
+
+	catchUp := scheduledJob{
+		job:       job,
+		jobType:   JobTypeBackup,
+		scheduler: s,
+		stopped:   entry.stopped,
+		trigger:   TriggerCatchUp,
+	}
+
+	job.Logger().Printf("Missed a scheduled run; queueing a catch-up run")
+
+	catchUp.submitDetached()
+
+	return true
+}
+
+// upstreamCompleted records that a run of job completed. Jobs depending on it are triggered once
+// every job they depend on has completed since they last ran. If any of those failed, the dependent
+// job is skipped unless its on_upstream_failure is set to run.
+func (s *Scheduler) upstreamCompleted(job Job, success bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.started {
+		return
+	}
+
+	for _, dependent := range s.downstream[job.Name] {
+		entry, ok := s.entries[dependent.Name]
+		if !ok {
+			continue
+		}
+
+		results := s.upstreamResults[dependent.Name]
+		results[job.Name] = success
+
+		if len(results) < len(NewSetFrom(dependent.DependsOn)) {
+			continue
+		}
+
+		failed := slices.Contains(slices.Collect(maps.Values(results)), false)
+		s.upstreamResults[dependent.Name] = map[string]bool{}
+
+		if failed && dependent.OnUpstreamFailure != UpstreamFailureRun {
+			dependent.RecordSkipped(JobTypeBackup, SkipReasonUpstreamFailure)
+
+			continue
+		}
+
+		dependent.Logger().Printf("Jobs it depends on have completed; queueing run")
+
+		scheduledJob{
+			job:       dependent,
+			jobType:   JobTypeBackup,
+			scheduler: s,
+			stopped:   entry.stopped,
+			trigger:   TriggerUpstream,
+		}.submitDetached()
+	}
+}
+
+// SetMaxConcurrentJobs limits how many job runs may execute at once. Additional runs wait in a
+// FIFO queue. A value of 0 removes the limit.
+func (s *Scheduler) SetMaxConcurrentJobs(maxConcurrent int) {
+	s.queue.SetMaxConcurrent(maxConcurrent)
+}
+
+// ActiveJobs describes the scheduled jobs as well as which are running or queued.
+type ActiveJobs struct {
+	ActiveJobs   []string             `json:"active_jobs"`
+	RunningJobs  []string             `json:"running_jobs"`
+	QueuedJobs   []string             `json:"queued_jobs"`
+	DeferredJobs map[string]time.Time `json:"deferred_jobs"`
+	Timezones    map[string]string    `json:"timezones"`
+	Jobs         map[string]ActiveJob `json:"jobs"`
+	// PausedAll is set if all jobs are paused.
+	PausedAll bool `json:"paused_all"`
+}
+
+// ActiveJob describes the schedule and current state of a scheduled job.
+type ActiveJob struct {
+	// Schedule is the backup schedule as configured. It is empty for jobs run after their upstreams.
+	Schedule string `json:"schedule"`
+	// NextRun and PrevRun are the next and previous times the backup schedule fires. PrevRun is
+	// only set once it has fired since the job was scheduled.
+	NextRun *time.Time `json:"next_run,omitempty"`
+	PrevRun *time.Time `json:"prev_run,omitempty"`
+	// NextMaintenance is the next time each of the forget, check and restore verification schedules fire.
+	NextMaintenance map[string]time.Time `json:"next_maintenance,omitempty"`
+	// LastStart and LastEnd are when the last backup run started and finished.
+	LastStart *time.Time `json:"last_start,omitempty"`
+	LastEnd   *time.Time `json:"last_end,omitempty"`
+	// State is one of JobStateIdle, JobStateRunning, JobStateQueued or JobStateDeferred.
+	State string `json:"state"`
+	// CurrentTask is the name of the task a running job is on.
+	CurrentTask string `json:"current_task,omitempty"`
+	// Paused is set if the job, or all jobs, are paused and won't start scheduled runs.
+	Paused bool `json:"paused"`
+}
+
+// Active returns a snapshot of the scheduled, running and queued jobs.
+func (s *Scheduler) Active() ActiveJobs {
+	running := s.queue.RunningJobNames()
+	queued := s.queue.QueuedJobNames()
+	runningSet := NewSetFrom(running)
+	queuedSet := NewSetFrom(queued)
+
+	s.mu.Lock()
+	timezones := make(map[string]string, len(s.jobs))
+	jobs := make(map[string]ActiveJob, len(s.jobs))
+
+	for _, job := range s.jobs {
+		timezones[job.Name] = job.Location().String()
+
+		activeJob := s.activeJobLocked(job)
+		_, isDeferred := s.deferred[job.Name]
+
+		switch {
+		case runningSet.Contains(job.Name):
+			activeJob.State = JobStateRunning
+			activeJob.CurrentTask = CurrentTask(job.Name)
+		case queuedSet.Contains(job.Name):
+			activeJob.State = JobStateQueued
+		case isDeferred:
+			activeJob.State = JobStateDeferred
+		}
+
+		jobs[job.Name] = activeJob
+	}
+
+	deferred := make(map[string]time.Time, len(s.deferred))
+	for name, deferral := range s.deferred {
+		deferred[name] = deferral.until
+	}
+
+	pausedAll := s.pausedAll
+	s.mu.Unlock()
+
+	return ActiveJobs{
+		ActiveJobs:   s.ActiveJobNames(),
+		RunningJobs:  running,
+		QueuedJobs:   queued,
+		DeferredJobs: deferred,
+		Timezones:    timezones,
+		Jobs:         jobs,
+		PausedAll:    pausedAll,
+	}
+}
+
+// activeJobLocked describes the schedule and last run of an idle job. The caller must hold s.mu.
+func (s *Scheduler) activeJobLocked(job Job) ActiveJob {
+	activeJob := ActiveJob{
+		Schedule:        job.Schedule,
+		NextRun:         nil,
+		PrevRun:         nil,
+		NextMaintenance: nil,
+		LastStart:       nil,
+		LastEnd:         nil,
+		State:           JobStateIdle,
+		CurrentTask:     "",
+		Paused:          s.isPausedLocked(job.Name),
+	}
+
+	if entry, ok := s.entries[job.Name]; ok {
+		for jobType, id := range entry.ids {
+			cronEntry := s.cron.Entry(id)
+
+			if jobType == JobTypeBackup {
+				activeJob.NextRun = optionalTime(cronEntry.Next)
+				activeJob.PrevRun = optionalTime(cronEntry.Prev)
+
+				continue
+			}
+
+			if !cronEntry.Next.IsZero() {
+				if activeJob.NextMaintenance == nil {
+					activeJob.NextMaintenance = map[string]time.Time{}
+				}
+
+				activeJob.NextMaintenance[jobType] = cronEntry.Next
+			}
+		}
+	}
+
+	jobResultsLock.Lock()
+	lastResult, ok := jobResults[jobResultKey{jobName: job.Name, jobType: JobTypeBackup}]
+	jobResultsLock.Unlock()
+
+	if ok {
+		activeJob.LastStart = optionalTime(lastResult.StartTime)
+		activeJob.LastEnd = optionalTime(lastResult.EndTime)
+	}
+
+	return activeJob
+}
+
+// optionalTime returns a pointer to t, or nil if t is the zero time, so it can be omitted from JSON.
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+
+	return &t
+}
+
+// setCurrentTask records the task a job is running. An empty task clears it.
+func setCurrentTask(jobName, task string) {
+	currentTasksLock.Lock()
+	defer currentTasksLock.Unlock()
+
+	if task == "" {
+		delete(currentTasks, jobName)
+		return
+	}
+
+	currentTasks[jobName] = task
+}
+
+// CurrentTask returns the name of the task the job is running, or an empty string if it isn't running.
+func CurrentTask(jobName string) string {
+	currentTasksLock.Lock()
+	defer currentTasksLock.Unlock()
+
+	return currentTasks[jobName]
+}
+
+// Job returns the scheduled job with the provided name.
+func (s *Scheduler) Job(name string) (Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[name]
+	if !ok {
+		return Job{}, false //nolint:exhaustruct
 	}
+
+	return entry.job, true
+}
+
+// Running returns true if the scheduler has been started and not stopped.
+func (s *Scheduler) Running() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.started
 }
 
 // ActiveJobNames returns a snapshot of the currently scheduled job names.
@@ -141,103 +1099,240 @@ type JobResult struct {
 	JobName   string
 	JobType   string
 	Success   bool
+	Status    string
 	LastError error
 	Message   string
+	QueueWait time.Duration
+	Attempts  int
+	Trigger   string
+	// StartTime and EndTime are when the run started and finished, excluding time spent queued.
+	StartTime time.Time
+	EndTime   time.Time
+	// SnapshotID is the ID of the snapshot created by a successful backup run.
+	SnapshotID string
+	// Tasks are the outcomes of each task run by a backup run.
+	Tasks []TaskOutcome
+	// RunID identifies the run in the queue, if it was queued.
+	RunID string
+}
+
+// stoppedWithScheduler returns true if the run was cancelled because the scheduler stopped. Those
+// runs say nothing about the health of the job, so they are recorded in the history but don't
+// replace the last result or send notifications.
+func (r JobResult) stoppedWithScheduler() bool {
+	return r.Status == JobStatusCancelled && r.Message == stoppedMessage
 }
 
 func (r JobResult) Format() string {
 	return fmt.Sprintf("%s %s ok? %v\n\n%+v", r.JobName, r.JobType, r.Success, r.LastError)
 }
 
-// JobComplete records completion state for a job into the in-memory map.
+// JobComplete records completion state for a job into the in-memory map and, if enabled, the
+// persisted run history, then sends any notifications configured for it. Skipped runs are only
+// recorded in the history so that they don't hide the result of the last run that did start.
 func JobComplete(result JobResult) {
 	log.Printf("Completed job %+v\n", result)
 
-	jobResultsLock.Lock()
-	jobResults[result.JobName] = result
-	jobResultsLock.Unlock()
+	if result.Status != JobStatusSkipped && !result.stoppedWithScheduler() {
+		jobResultsLock.Lock()
+		jobResults[jobResultKey{jobName: result.JobName, jobType: result.JobType}] = result
+		jobResultsLock.Unlock()
+
+		Notify(result)
+	}
+
+	if History != nil {
+		if err := History.Append(NewRunRecord(result)); err != nil {
+			log.Printf("ERROR: Failed recording run history for job %s: %v", result.JobName, err)
+		}
+	}
 }
 
-// writeJobResult writes the job result as JSON to the provided writer.
-func writeJobResult(writer http.ResponseWriter, jobName string) {
+// writeJobResult writes the result of the job type for the job as JSON to the provided writer. If
+// the health of the job is provided, it sets the status code and is included in the response.
+// Otherwise the status code is that of the result and 404 if there is none.
+func writeJobResult(writer http.ResponseWriter, jobName, jobType string, health *JobHealth) {
 	writer.Header().Set("Content-Type", "application/json")
 
 	jobResultsLock.Lock()
-	jobResult, ok := jobResults[jobName]
+	jobResult, ok := jobResults[jobResultKey{jobName: jobName, jobType: jobType}]
 	jobResultsLock.Unlock()
 
-	if ok {
-		if !jobResult.Success {
-			// Set a 503 status code if the last job run was not successful
-			writer.WriteHeader(http.StatusServiceUnavailable)
-		}
+	if !ok && health == nil {
+		// Job not found
+		writer.WriteHeader(http.StatusNotFound)
+		_, _ = writer.Write([]byte("{\"Message\": \"Unknown job\"}"))
+
+		return
+	}
+
+	// Build a JSON object that maps to the exported JobResult fields (excluding the LastError field,
+	// which cannot be marshalled directly). Using the exported field names ensures compatibility
+	// with tests that unmarshal into main.JobResult.
+	out := map[string]interface{}{
+		"JobName": jobName,
+		"JobType": jobType,
+	}
 
+	healthy := jobResult.Success
+
+	if ok {
 		// Set message from LastError if available
 		if jobResult.LastError != nil {
 			jobResult.Message = jobResult.LastError.Error()
 		}
 
-		// Build a JSON object that maps to the exported JobResult fields (excluding the LastError field,
-		// which cannot be marshalled directly). Using the exported field names ensures compatibility
-		// with tests that unmarshal into main.JobResult.
-		out := map[string]interface{}{
-			"JobName": jobResult.JobName,
-			"JobType": jobResult.JobType,
-			"Success": jobResult.Success,
-			"Message": jobResult.Message,
-		}
+		out["Success"] = jobResult.Success
+		out["Status"] = jobResult.Status
+		out["Message"] = jobResult.Message
+		out["QueueWait"] = jobResult.QueueWait
+		out["Attempts"] = jobResult.Attempts
+		out["Trigger"] = jobResult.Trigger
+	}
 
-		if err := json.NewEncoder(writer).Encode(out); err != nil {
-			http.Error(writer, fmt.Sprintf("failed writing json for %s", jobResult.JobName), http.StatusInternalServerError)
-		}
+	if health != nil {
+		healthy = health.Healthy
+		out["Healthy"] = health.Healthy
+		out["Problems"] = health.Problems
+	}
 
-		return
+	if !healthy {
+		// Set a 503 status code if the job is unhealthy or the last job run was not successful
+		writer.WriteHeader(http.StatusServiceUnavailable)
 	}
 
-	// Job not found
-	writer.WriteHeader(http.StatusNotFound)
-	_, _ = writer.Write([]byte("{\"Message\": \"Unknown job\"}"))
+	if err := json.NewEncoder(writer).Encode(out); err != nil {
+		http.Error(writer, fmt.Sprintf("failed writing json for %s", jobName), http.StatusInternalServerError)
+	}
 }
 
-// HealthHandleFunc handles health check requests.
-func HealthHandleFunc(writer http.ResponseWriter, request *http.Request) {
+// HealthHandleFunc handles health check requests. The result of a job's backup is returned for a
+// job query, or of another operation if a type query, such as type=check, is included. For jobs
+// scheduled by the provided scheduler, the status code is from the job's health, like for
+// /health/all. The scheduler may be nil.
+func HealthHandleFunc(writer http.ResponseWriter, request *http.Request, sched *Scheduler) {
 	query := request.URL.Query()
 	if jobName, ok := query["job"]; ok {
-		writeJobResult(writer, jobName[0])
+		var health *JobHealth
+
+		if sched != nil {
+			if job, ok := sched.Job(jobName[0]); ok {
+				jobHealth := job.Health(time.Now())
+				health = &jobHealth
+			}
+		}
+
+		writeJobResult(writer, jobName[0], cmp.Or(query.Get("type"), JobTypeBackup), health)
+
 		return
 	}
 
 	_, _ = writer.Write([]byte("ok"))
 }
 
-// ActiveHandleFunc returns the currently scheduled job names. It expects a scheduler
-// instance to be provided via closure in RunHTTPHandlers.
-func ActiveHandleFunc(writer http.ResponseWriter, request *http.Request, names []string) {
+// ActiveHandleFunc returns the currently scheduled, running and queued job names. It expects a
+// scheduler instance to be provided via closure in RunHTTPHandlers.
+func ActiveHandleFunc(writer http.ResponseWriter, request *http.Request, active ActiveJobs) {
 	writer.Header().Set("Content-Type", "application/json")
 
-	if err := json.NewEncoder(writer).Encode(map[string][]string{"active_jobs": names}); err != nil {
+	if err := json.NewEncoder(writer).Encode(active); err != nil {
 		http.Error(writer, "failed to encode active jobs", http.StatusInternalServerError)
 	}
 }
 
-// RunHTTPHandlers registers HTTP handlers for /health, /metrics and /active.
-// The active handler uses the provided scheduler to get the current job names.
-func RunHTTPHandlers(addr string, sched *Scheduler) error {
-	http.HandleFunc("/health", HealthHandleFunc)
-	http.Handle("/metrics", promhttp.HandlerFor(
+// schedulerHandler returns a handler that calls handle with the scheduler, or responds that the
+// scheduler isn't running if there is none.
+func schedulerHandler(
+	sched *Scheduler,
+	handle func(http.ResponseWriter, *http.Request, *Scheduler),
+) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if sched == nil {
+			http.Error(writer, "scheduler is not running", http.StatusServiceUnavailable)
+			return
+		}
+
+		handle(writer, request, sched)
+	}
+}
+
+// RunHTTPHandlers registers HTTP handlers for /health, /livez, /readyz, /history, /metrics, /active, the dashboard
+// and managing jobs at runtime, then serves them with the provided options.
+// The active and run handlers use the provided scheduler to get and queue jobs.
+func RunHTTPHandlers(addr string, sched *Scheduler, options HTTPOptions) error {
+	if err := options.Validate(); err != nil {
+		return err
+	}
+
+	tlsConfig, err := options.TLSConfig()
+	if err != nil {
+		return err
+	}
+
+	auth := options.Auth
+	if auth == nil {
+		auth = NewAuthenticator(nil)
+	}
+
+	metricsHandler := promhttp.HandlerFor(
 		Metrics.Registry,
 		promhttp.HandlerOpts{Registry: Metrics.Registry}, //nolint:exhaustruct
-	))
+	)
+
+	http.HandleFunc("/health", auth.Require(ScopeHealth, func(w http.ResponseWriter, r *http.Request) {
+		HealthHandleFunc(w, r, sched)
+	}))
+	http.HandleFunc("/health/all", auth.Require(ScopeHealth, schedulerHandler(sched, HealthAllHandleFunc)))
+	http.HandleFunc("/livez", auth.Require(ScopeHealth, LivezHandleFunc))
+	http.HandleFunc("/readyz", auth.Require(ScopeHealth, schedulerHandler(sched, ReadyzHandleFunc)))
+	http.HandleFunc("/metrics", auth.Require(ScopeHealth, metricsHandler.ServeHTTP))
+	http.HandleFunc("/history", auth.Require(ScopeRead, History.HandleFunc))
+
+	http.HandleFunc("POST /jobs/{name}/run", auth.Require(ScopeWrite, schedulerHandler(sched, RunJobHandleFunc)))
+	http.HandleFunc("POST /jobs/{name}/cancel", auth.Require(ScopeWrite, schedulerHandler(sched, CancelHandleFunc)))
+	http.HandleFunc("GET /runs/{id}", auth.Require(ScopeRead, schedulerHandler(sched, RunStatusHandleFunc)))
+	http.HandleFunc("GET /jobs/{name}/logs", auth.Require(ScopeRead, schedulerHandler(sched, LogsHandleFunc)))
+	http.HandleFunc("GET /{$}", auth.Require(ScopeRead, schedulerHandler(sched, DashboardHandleFunc)))
+	http.HandleFunc("GET /jobs/{name}", auth.Require(ScopeRead, schedulerHandler(sched, DashboardJobHandleFunc)))
+
+	pause := func(w http.ResponseWriter, r *http.Request, sched *Scheduler) { PauseHandleFunc(w, r, sched, true) }
+	resume := func(w http.ResponseWriter, r *http.Request, sched *Scheduler) { PauseHandleFunc(w, r, sched, false) }
+
+	http.HandleFunc("POST /jobs/{name}/pause", auth.Require(ScopeWrite, schedulerHandler(sched, pause)))
+	http.HandleFunc("POST /jobs/{name}/resume", auth.Require(ScopeWrite, schedulerHandler(sched, resume)))
+	http.HandleFunc("POST /pause", auth.Require(ScopeWrite, schedulerHandler(sched, pause)))
+	http.HandleFunc("POST /resume", auth.Require(ScopeWrite, schedulerHandler(sched, resume)))
 
 	// active handler closure
-	http.HandleFunc("/active", func(w http.ResponseWriter, r *http.Request) {
+	http.HandleFunc("/active", auth.Require(ScopeRead, func(w http.ResponseWriter, r *http.Request) {
 		if sched == nil {
-			ActiveHandleFunc(w, r, []string{})
+			ActiveHandleFunc(w, r, ActiveJobs{
+				ActiveJobs:   []string{},
+				RunningJobs:  []string{},
+				QueuedJobs:   []string{},
+				DeferredJobs: map[string]time.Time{},
+				Timezones:    map[string]string{},
+				Jobs:         map[string]ActiveJob{},
+				PausedAll:    false,
+			})
 			return
 		}
 
-		ActiveHandleFunc(w, r, sched.ActiveJobNames())
-	})
+		ActiveHandleFunc(w, r, sched.Active())
+	}))
+
+	if tlsConfig == nil {
+		return fmt.Errorf("error on http server: %w", http.ListenAndServe(addr, nil)) //#nosec: g114
+	}
+
+	server := &http.Server{ //nolint:exhaustruct
+		Addr:              addr,
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: httpReadHeaderTimeout,
+	}
 
-	return fmt.Errorf("error on http server: %w", http.ListenAndServe(addr, nil)) //#nosec: g114
+	return fmt.Errorf(
+		"error on https server: %w",
+		server.ListenAndServeTLS(options.TLSCertFile, options.TLSKeyFile),
+	)
 }
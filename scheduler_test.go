@@ -4,9 +4,15 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	main "git.iamthefij.com/iamthefij/restic-scheduler"
+	"github.com/robfig/cron/v3"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -56,8 +62,7 @@ func TestHealthHandleFunc(t *testing.T) {
 	}
 
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(main.HealthHandleFunc)
-	handler.ServeHTTP(rr, req)
+	main.HealthHandleFunc(rr, req, nil)
 
 	assert.Equal(t, http.StatusOK, rr.Code)
 	assert.Equal(t, "ok", rr.Body.String())
@@ -80,7 +85,7 @@ func TestHealthHandleFunc(t *testing.T) {
 	}
 
 	rr = httptest.NewRecorder()
-	handler.ServeHTTP(rr, req)
+	main.HealthHandleFunc(rr, req, nil)
 
 	assert.Equal(t, http.StatusOK, rr.Code)
 
@@ -106,8 +111,7 @@ func TestHealthHandleFuncUnknownJob(t *testing.T) {
 	}
 
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(main.HealthHandleFunc)
-	handler.ServeHTTP(rr, req)
+	main.HealthHandleFunc(rr, req, nil)
 
 	assert.Equal(t, http.StatusNotFound, rr.Code)
 	assert.Contains(t, rr.Body.String(), "Unknown job")
@@ -133,8 +137,7 @@ func TestHealthHandleFuncFailedJob(t *testing.T) {
 	}
 
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(main.HealthHandleFunc)
-	handler.ServeHTTP(rr, req)
+	main.HealthHandleFunc(rr, req, nil)
 
 	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
 
@@ -150,3 +153,414 @@ func TestHealthHandleFuncFailedJob(t *testing.T) {
 	assert.False(t, responseResult.Success)
 	assert.NotEmpty(t, responseResult.Message)
 }
+
+func TestHealthHandleFuncJobType(t *testing.T) {
+	t.Parallel()
+
+	main.JobComplete(main.JobResult{ //nolint:exhaustruct
+		JobName: "TestJobTypes",
+		JobType: main.JobTypeBackup,
+		Success: true,
+	})
+	main.JobComplete(main.JobResult{ //nolint:exhaustruct
+		JobName: "TestJobTypes",
+		JobType: main.JobTypeCheck,
+		Success: false,
+		Message: "check failed",
+	})
+
+	cases := []struct {
+		query        string
+		expectedCode int
+		expectedType string
+	}{
+		{query: "job=TestJobTypes", expectedCode: http.StatusOK, expectedType: main.JobTypeBackup},
+		{query: "job=TestJobTypes&type=check", expectedCode: http.StatusServiceUnavailable, expectedType: main.JobTypeCheck},
+		{query: "job=TestJobTypes&type=forget", expectedCode: http.StatusNotFound, expectedType: ""},
+	}
+
+	for _, c := range cases {
+		testCase := c
+
+		t.Run(testCase.query, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodGet, "/health?"+testCase.query, nil)
+			rr := httptest.NewRecorder()
+			main.HealthHandleFunc(rr, req, nil)
+
+			assert.Equal(t, testCase.expectedCode, rr.Code)
+
+			if testCase.expectedType != "" {
+				var responseResult main.JobResult
+
+				assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &responseResult))
+				assert.Equal(t, testCase.expectedType, responseResult.JobType)
+			}
+		})
+	}
+}
+
+func TestOverlapWrapperSkip(t *testing.T) {
+	t.Parallel()
+
+	job := main.Job{ //nolint:exhaustruct
+		Name:     "TestOverlapSkipJob",
+		Schedule: "@daily",
+		Overlap:  main.OverlapSkip,
+		Config:   ValidResticConfig(),
+	}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	runs := 0
+
+	wrapped := main.OverlapWrapper(job)(cron.FuncJob(func() {
+		runs++
+
+		close(started)
+		<-release
+	}))
+
+	done := make(chan struct{})
+
+	go func() {
+		wrapped.Run()
+		close(done)
+	}()
+
+	<-started
+
+	main.JobComplete(main.JobResult{ //nolint:exhaustruct
+		JobName: job.Name,
+		JobType: main.JobTypeBackup,
+		Success: false,
+		Status:  main.JobStatusFailure,
+	})
+
+	// A second tick while the first run is in progress should return immediately
+	wrapped.Run()
+	close(release)
+	<-done
+
+	assert.Equal(t, 1, runs)
+
+	req, err := http.NewRequest("GET", "/health?job=TestOverlapSkipJob", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	main.HealthHandleFunc(rr, req, nil)
+
+	var responseResult main.JobResult
+
+	err = json.Unmarshal(rr.Body.Bytes(), &responseResult)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The skipped run doesn't hide the result of the last run that started
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Equal(t, main.JobStatusFailure, responseResult.Status)
+}
+
+func TestDiffJobs(t *testing.T) {
+	t.Parallel()
+
+	unchanged := main.Job{Name: "unchanged", Schedule: "@daily"} //nolint:exhaustruct
+	changed := main.Job{Name: "changed", Schedule: "@daily"}     //nolint:exhaustruct
+	removed := main.Job{Name: "removed", Schedule: "@daily"}     //nolint:exhaustruct
+	added := main.Job{Name: "added", Schedule: "@daily"}         //nolint:exhaustruct
+
+	changedNew := changed
+	changedNew.Schedule = "@hourly"
+
+	changes := main.DiffJobs(
+		[]main.Job{unchanged, changed, removed},
+		[]main.Job{unchanged, changedNew, added},
+	)
+
+	assert.Equal(t, []string{"added"}, changes.Added)
+	assert.Equal(t, []string{"changed"}, changes.Changed)
+	assert.Equal(t, []string{"removed"}, changes.Removed)
+	assert.Equal(t, []string{"unchanged"}, changes.Unchanged)
+}
+
+func TestSchedulerReplaceJobs(t *testing.T) {
+	t.Parallel()
+
+	sched := main.NewScheduler()
+
+	err := sched.Start([]main.Job{
+		{Name: "first", Schedule: "@daily"},  //nolint:exhaustruct
+		{Name: "second", Schedule: "@daily"}, //nolint:exhaustruct
+	})
+	AssertEqualFail(t, "unexpected error starting scheduler", nil, err)
+
+	err = sched.ReplaceJobs([]main.Job{
+		{Name: "second", Schedule: "@hourly"},          //nolint:exhaustruct
+		{Name: "third", DependsOn: []string{"second"}}, //nolint:exhaustruct
+	})
+	AssertEqualFail(t, "unexpected error replacing jobs", nil, err)
+	assert.Equal(t, []string{"second", "third"}, sched.ActiveJobNames())
+
+	// An invalid schedule should leave the current jobs in place
+	err = sched.ReplaceJobs([]main.Job{
+		{Name: "fourth", Schedule: "not a schedule"}, //nolint:exhaustruct
+	})
+	assert.Error(t, err)
+	assert.Equal(t, []string{"second", "third"}, sched.ActiveJobNames())
+
+	sched.StopGraceful(0)
+}
+
+func TestSchedulerActive(t *testing.T) {
+	t.Parallel()
+
+	sched := main.NewScheduler()
+
+	err := sched.Start([]main.Job{
+		//nolint:exhaustruct
+		{
+			Name:           "TestActiveJob",
+			Schedule:       "@daily",
+			Forget:         &main.ForgetOpts{KeepLast: 1}, //nolint:exhaustruct
+			ForgetSchedule: "@weekly",
+		},
+		{Name: "TestActiveDependent", DependsOn: []string{"TestActiveJob"}}, //nolint:exhaustruct
+	})
+	AssertEqualFail(t, "unexpected error starting scheduler", nil, err)
+
+	defer sched.StopGraceful(0)
+
+	active := sched.Active()
+	assert.Equal(t, []string{"TestActiveJob", "TestActiveDependent"}, active.ActiveJobs)
+
+	job := active.Jobs["TestActiveJob"]
+	assert.Equal(t, "@daily", job.Schedule)
+	assert.Equal(t, main.JobStateIdle, job.State)
+	assert.Nil(t, job.PrevRun)
+
+	if assert.NotNil(t, job.NextRun) {
+		assert.True(t, job.NextRun.After(time.Now()))
+	}
+
+	assert.Contains(t, job.NextMaintenance, main.JobTypeForget)
+
+	dependent := active.Jobs["TestActiveDependent"]
+	assert.Equal(t, main.JobStateIdle, dependent.State)
+	assert.Nil(t, dependent.NextRun)
+}
+
+// TestSchedulerCatchUp replaces restic and records history, so it can't run in parallel.
+func TestSchedulerCatchUp(t *testing.T) {
+	fakeRestic(t, "case \"$*\" in\n"+
+		"*snapshots*) echo '[{\"time\":\"2020-01-01T00:00:00Z\",\"id\":\"abc123\",\"short_id\":\"abc\"}]' ;;\n"+
+		"esac\n")
+
+	store := recordHistory(t)
+	latest := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	catchUp := main.Job{ //nolint:exhaustruct
+		Name:     uniqueJobName("TestSchedulerCatchUp"),
+		Schedule: "@daily",
+		CatchUp:  true,
+		Config:   ValidResticConfig(),
+	}
+	noCatchUp := catchUp
+	noCatchUp.Name = uniqueJobName("TestSchedulerNoCatchUp")
+	noCatchUp.CatchUp = false
+	noCatchUp.Config = &main.ResticConfig{Passphrase: "shh", Repo: "./other"} //nolint:exhaustruct
+
+	sched := main.NewScheduler()
+
+	err := sched.Start([]main.Job{catchUp, noCatchUp})
+	AssertEqualFail(t, "unexpected error starting scheduler", nil, err)
+
+	defer sched.StopGraceful(0)
+
+	assert.False(t, sched.CatchUp(catchUp, time.Time{}), "job without snapshots can't detect a missed run")
+	assert.False(t, sched.CatchUp(noCatchUp, latest), "job without catch_up shouldn't catch up")
+	assert.True(t, sched.CatchUp(catchUp, latest))
+
+	records := waitForHistory(t, store, catchUp.Name, 1)
+	AssertEqual(t, "unexpected trigger", main.TriggerCatchUp, records[0].Trigger)
+	AssertEqual(t, "unexpected status", main.JobStatusSuccess, records[0].Status)
+
+	sched.StopGraceful(0)
+
+	all, err := store.Read("", 0)
+	AssertEqualFail(t, "unexpected error reading history", nil, err)
+	AssertEqual(t, "unexpected run count", 1, len(all))
+}
+
+func TestSchedulerCatchUpWhileWaiting(t *testing.T) {
+	t.Parallel()
+
+	job := main.Job{ //nolint:exhaustruct
+		Name:     uniqueJobName("TestSchedulerCatchUpWhileWaiting"),
+		Schedule: "@every 1s",
+		Jitter:   "1h",
+		CatchUp:  true,
+		Config:   &main.ResticConfig{Passphrase: "shh", Repo: "/repo/waiting"}, //nolint:exhaustruct
+	}
+
+	sched := main.NewScheduler()
+
+	err := sched.Start([]main.Job{job})
+	AssertEqualFail(t, "unexpected error starting scheduler", nil, err)
+
+	defer sched.StopGraceful(0)
+
+	// Wait for the schedule to fire twice so that the first run is surely waiting out its jitter
+	var firstRun time.Time
+
+	assert.Eventually(t, func() bool {
+		prevRun := sched.Active().Jobs[job.Name].PrevRun
+		if prevRun == nil {
+			return false
+		}
+
+		if firstRun.IsZero() {
+			firstRun = *prevRun
+		}
+
+		return prevRun.After(firstRun)
+	}, 10*time.Second, 10*time.Millisecond)
+
+	assert.False(t, sched.CatchUp(job, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)), "run waiting out its jitter should prevent a catch-up run")
+	assert.Empty(t, sched.Active().QueuedJobs)
+}
+
+// TestSchedulerStopGraceful replaces restic with a script that sleeps, so it can't run in parallel.
+func TestSchedulerStopGraceful(t *testing.T) {
+	fakeRestic(t, "case \"$*\" in\n"+
+		"*snapshots*) echo '[]' ;;\n"+
+		"*unlock*) ;;\n"+
+		"*--repo\\ /repo/hang*) exec sleep 60 ;;\n"+
+		"*) sleep 1 ;;\n"+
+		"esac\n")
+
+	cases := []struct {
+		name    string
+		repo    string
+		timeout time.Duration
+	}{
+		{name: "Waits for manual runs", repo: "/repo/sleep", timeout: 0},
+		{name: "Terminates manual runs after timeout", repo: "/repo/hang", timeout: 100 * time.Millisecond},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			job := main.Job{ //nolint:exhaustruct
+				Name:     uniqueJobName("TestSchedulerStopGraceful"),
+				Schedule: "@daily",
+				Config:   &main.ResticConfig{Passphrase: "shh", Repo: c.repo}, //nolint:exhaustruct
+			}
+
+			sched := main.NewScheduler()
+
+			err := sched.Start([]main.Job{job})
+			AssertEqualFail(t, "unexpected error starting scheduler", nil, err)
+
+			// The second run is queued behind the first since they use the same repository
+			first, err := sched.RunNow(job.Name, main.JobTypeBackup)
+			AssertEqualFail(t, "unexpected error queueing run", nil, err)
+
+			second, err := sched.RunNow(job.Name, main.JobTypeCheck)
+			AssertEqualFail(t, "unexpected error queueing run", nil, err)
+
+			start := time.Now()
+
+			sched.StopGraceful(c.timeout)
+
+			if elapsed := time.Since(start); elapsed > main.KillGracePeriod {
+				t.Errorf("expected StopGraceful to return within %s, took %s", main.KillGracePeriod, elapsed)
+			}
+
+			status, err := sched.RunStatus(first)
+			AssertEqualFail(t, "unexpected error getting run status", nil, err)
+			assert.Equal(t, main.RunStateFinished, status.State)
+
+			status, err = sched.RunStatus(second)
+			AssertEqualFail(t, "unexpected error getting run status", nil, err)
+
+			if c.timeout == 0 {
+				assert.Equal(t, main.RunStateFinished, status.State)
+			} else {
+				assert.Equal(t, main.RunStateDropped, status.State)
+			}
+		})
+	}
+}
+
+// TestSchedulerStopNow replaces restic with a script that ignores SIGTERM and changes the kill grace
+// period, so it can't run in parallel.
+func TestSchedulerStopNow(t *testing.T) {
+	calls := filepath.Join(t.TempDir(), "calls")
+
+	fakeRestic(t, "echo \"$*\" >> "+calls+"\n"+
+		"case \"$*\" in\n"+
+		"*snapshots*) echo '[]' ;;\n"+
+		"*unlock*) ;;\n"+
+		"*) trap 'echo terminated >> "+calls+"' TERM; while :; do sleep 1; done ;;\n"+
+		"esac\n")
+
+	previousGrace := main.KillGracePeriod
+	main.KillGracePeriod = 500 * time.Millisecond
+
+	t.Cleanup(func() { main.KillGracePeriod = previousGrace })
+
+	job := main.Job{ //nolint:exhaustruct
+		Name:     uniqueJobName("TestSchedulerStopNow"),
+		Schedule: "@daily",
+		Config:   &main.ResticConfig{Passphrase: "shh", Repo: "/repo/stop-now"}, //nolint:exhaustruct
+	}
+
+	sched := main.NewScheduler()
+
+	err := sched.Start([]main.Job{job})
+	AssertEqualFail(t, "unexpected error starting scheduler", nil, err)
+
+	id, err := sched.RunNow(job.Name, main.JobTypeCheck)
+	AssertEqualFail(t, "unexpected error queueing run", nil, err)
+
+	// Wait for restic to be running the check so that it is the process terminated
+	assert.Eventually(t, func() bool {
+		content, err := os.ReadFile(calls)
+
+		return err == nil && strings.Contains(string(content), " check")
+	}, 10*time.Second, 10*time.Millisecond)
+
+	start := time.Now()
+
+	sched.StopNow()
+
+	// restic ignores SIGTERM, so it is only stopped by SIGKILL once the grace period has passed
+	if elapsed := time.Since(start); elapsed < main.KillGracePeriod {
+		t.Errorf("expected StopNow to wait %s for the run to be killed, took %s", main.KillGracePeriod, elapsed)
+	}
+
+	status, err := sched.RunStatus(id)
+	AssertEqualFail(t, "unexpected error getting run status", nil, err)
+	assert.Equal(t, main.RunStateFinished, status.State)
+
+	if assert.NotNil(t, status.Result) {
+		assert.Equal(t, main.JobStatusCancelled, status.Result.Status)
+		assert.Equal(t, "stopped with the scheduler", status.Result.Message)
+	}
+
+	// The check was sent SIGTERM, then the repository was unlocked once it had been killed
+	content, err := os.ReadFile(calls)
+	AssertEqualFail(t, "unexpected error reading restic calls", nil, err)
+
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	terminated := slices.Index(lines, "terminated")
+	unlock := slices.IndexFunc(lines, func(line string) bool {
+		return strings.Contains(line, "--repo /repo/stop-now unlock")
+	})
+
+	assert.GreaterOrEqual(t, terminated, 0, "expected check to be sent SIGTERM")
+	assert.Greater(t, unlock, terminated, "expected repository to be unlocked after the check was terminated")
+}
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/exec"
@@ -36,25 +39,32 @@ func (s *loggerMap) load(name string) (*log.Logger, bool) {
 	return x.(*log.Logger), true
 }
 
-// GetLogger gets a logger by name or creates one if it doesn't exist yet.
+// GetLogger gets a logger by name or creates one if it doesn't exist yet. Output is written to
+// stderr and kept in the log buffer of the same name.
 func GetLogger(name string) *log.Logger {
+	return getLogger(name, io.MultiWriter(os.Stderr, GetLogBuffer(name)))
+}
+
+// GetChildLogger gets a logger appending the name to the parent logger name. Output is written to
+// the same place as the parent's.
+func GetChildLogger(parent *log.Logger, name string) *log.Logger {
+	childName := fmt.Sprintf("%s%s", parent.Prefix(), name)
+
+	return getLogger(childName, parent.Writer())
+}
+
+// getLogger gets a logger by name or creates one writing to writer if it doesn't exist yet.
+func getLogger(name string, writer io.Writer) *log.Logger {
 	if logger, ok := loggers.load(name); ok {
 		return logger
 	}
 
-	logger := log.New(os.Stderr, name+":", loggerFlags)
+	logger := log.New(writer, name+":", loggerFlags)
 	loggers.store(name, logger)
 
 	return logger
 }
 
-// GetChildLogger gets a logger appending the name to the parent logger name.
-func GetChildLogger(parent *log.Logger, name string) *log.Logger {
-	childName := fmt.Sprintf("%s%s", parent.Prefix(), name)
-
-	return GetLogger(childName)
-}
-
 // CapturedLogWriter is a writer that stores the written lines in an array.
 type CapturedLogWriter struct {
 	Lines  []string
@@ -108,8 +118,11 @@ func (cclw CapturedCommandLogWriter) AllLines() []string {
 }
 
 // RunShell runs a given script string  in a given directory with the provided environment variables and logs to the provided logger.
-func RunShell(script string, cwd string, env map[string]string, logger *log.Logger) error {
-	cmd := exec.Command("sh", "-c", strings.TrimSpace(script)) //nolint:gosec
+// If ctx is done before the script exits, the script and any processes it started are killed.
+func RunShell(ctx context.Context, script string, cwd string, env map[string]string, logger *log.Logger) error {
+	cmd := exec.CommandContext(ctx, "sh", "-c", strings.TrimSpace(script)) //nolint:gosec
+	stopKill := setProcessGroup(cmd)
+	defer stopKill()
 
 	// Make both stderr and stdout go to logger
 	output := NewCapturedCommandLogWriter(logger)
@@ -127,6 +140,10 @@ func RunShell(script string, cwd string, env map[string]string, logger *log.Logg
 	}
 
 	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			err = errors.Join(err, contextError(ctx))
+		}
+
 		return fmt.Errorf("shell execution failed: %w", err)
 	}
 
@@ -2,8 +2,11 @@ package main_test
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"log"
 	"testing"
+	"time"
 
 	main "git.iamthefij.com/iamthefij/restic-scheduler"
 )
@@ -129,6 +132,7 @@ func TestRunShell(t *testing.T) {
 			logger := log.New(&buffer, "prefix:", log.Lmsgprefix)
 
 			err := main.RunShell(
+				t.Context(),
 				testCase.script,
 				testCase.cwd,
 				testCase.env,
@@ -147,3 +151,26 @@ func TestRunShell(t *testing.T) {
 		})
 	}
 }
+
+func TestRunShellTimeout(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(t.Context(), 100*time.Millisecond)
+	defer cancel()
+
+	buffer := bytes.Buffer{}
+	logger := log.New(&buffer, "prefix:", log.Lmsgprefix)
+
+	// The background sleep holds the output pipes open, so this only returns quickly if the whole
+	// process group is killed.
+	start := time.Now()
+	err := main.RunShell(ctx, "sleep 5 & sleep 5; wait", ".", nil, logger)
+
+	if !errors.Is(err, main.ErrTimeout) {
+		t.Errorf("expected error to wrap %v but found %v", main.ErrTimeout, err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected script to be killed after timeout but it ran for %s", elapsed)
+	}
+}
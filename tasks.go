@@ -1,6 +1,8 @@
 package main
 
 import (
+	"cmp"
+	"context"
 	"errors"
 	"fmt"
 	"io/fs"
@@ -9,6 +11,9 @@ import (
 	"strings"
 )
 
+// ErrTask is returned when a task script fails.
+var ErrTask = errors.New("task error")
+
 type TaskConfig struct {
 	BackupPaths     []string
 	Env             map[string]string
@@ -17,10 +22,11 @@ type TaskConfig struct {
 	RestoreSnapshot string
 }
 
-// ExecutableTask is a task to be run before or after backup/retore.
+// ExecutableTask is a task to be run before or after backup/retore. Tasks should stop and return
+// an error once ctx is done.
 type ExecutableTask interface {
-	RunBackup(cfg TaskConfig) error
-	RunRestore(cfg TaskConfig) error
+	RunBackup(ctx context.Context, cfg TaskConfig) error
+	RunRestore(ctx context.Context, cfg TaskConfig) error
 	Name() string
 }
 
@@ -30,10 +36,11 @@ type JobTaskScript struct {
 	OnRestore string            `hcl:"on_restore,optional"`
 	Cwd       string            `hcl:"cwd,optional"`
 	Env       map[string]string `hcl:"env,optional"`
+	Timeout   string            `hcl:"timeout,optional"`
 	name      string
 }
 
-func (t JobTaskScript) run(script string, cfg TaskConfig) error {
+func (t JobTaskScript) run(ctx context.Context, script string, cfg TaskConfig) error {
 	if script == "" {
 		return nil
 	}
@@ -43,21 +50,29 @@ func (t JobTaskScript) run(script string, cfg TaskConfig) error {
 		env = map[string]string{}
 	}
 
-	if err := RunShell(script, t.Cwd, env, cfg.Logger); err != nil {
-		return fmt.Errorf("failed running task script %s: %w", t.Name(), err)
+	ctx, cancel := withOptionalTimeout(ctx, t.Timeout)
+	defer cancel()
+
+	if err := RunShell(ctx, script, t.Cwd, env, cfg.Logger); err != nil {
+		return fmt.Errorf("failed running task script %s: %w: %w", t.Name(), err, ErrTask)
 	}
 
 	return nil
 }
 
 // RunBackup runs script on backup.
-func (t JobTaskScript) RunBackup(cfg TaskConfig) error {
-	return t.run(t.OnBackup, cfg)
+func (t JobTaskScript) RunBackup(ctx context.Context, cfg TaskConfig) error {
+	return t.run(ctx, t.OnBackup, cfg)
 }
 
 // RunRestore script on restore.
-func (t JobTaskScript) RunRestore(cfg TaskConfig) error {
-	return t.run(t.OnRestore, cfg)
+func (t JobTaskScript) RunRestore(ctx context.Context, cfg TaskConfig) error {
+	return t.run(ctx, t.OnRestore, cfg)
+}
+
+// Validate ensures that this tasks configuration is valid.
+func (t JobTaskScript) Validate() error {
+	return validateTimeout("script timeout", t.Timeout)
 }
 
 // Name returns the name of this task.
@@ -83,6 +98,7 @@ type JobTaskMySQL struct {
 	SkipSSL       bool     `hcl:"skip_ssl,optional"`
 	DumpToPath    string   `hcl:"dump_to"`
 	UseMariaDB    bool     `hcl:"use_mariadb,optional"`
+	Timeout       string   `hcl:"timeout,optional"`
 }
 
 func (t JobTaskMySQL) mysqlCommand() string {
@@ -133,6 +149,10 @@ func (t JobTaskMySQL) Validate() error {
 		)
 	}
 
+	if err := validateTimeout("task "+t.Name+" timeout", t.Timeout); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -164,6 +184,7 @@ func (t JobTaskMySQL) GetPreTask() ExecutableTask {
 		Cwd:       ".",
 		OnBackup:  strings.Join(command, " "),
 		OnRestore: "",
+		Timeout:   t.Timeout,
 	}
 }
 
@@ -192,6 +213,7 @@ func (t JobTaskMySQL) GetPostTask() ExecutableTask {
 		Cwd:       ".",
 		OnBackup:  "",
 		OnRestore: strings.Join(command, " "),
+		Timeout:   t.Timeout,
 	}
 }
 
@@ -208,6 +230,7 @@ type JobTaskPostgres struct {
 	NoTablespaces bool     `hcl:"no_tablespaces,optional"`
 	Clean         bool     `hcl:"clean,optional"`
 	Create        bool     `hcl:"create,optional"`
+	Timeout       string   `hcl:"timeout,optional"`
 }
 
 // Paths returns all paths to be backed up from this task.
@@ -242,6 +265,10 @@ func (t JobTaskPostgres) Validate() error {
 		)
 	}
 
+	if err := validateTimeout("task "+t.Name+" timeout", t.Timeout); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -276,6 +303,7 @@ func (t JobTaskPostgres) GetPreTask() ExecutableTask {
 		Cwd:       ".",
 		OnBackup:  strings.Join(command, " "),
 		OnRestore: "",
+		Timeout:   t.Timeout,
 	}
 }
 
@@ -304,6 +332,7 @@ func (t JobTaskPostgres) GetPostTask() ExecutableTask {
 		Cwd:       ".",
 		OnBackup:  "",
 		OnRestore: strings.Join(command, " "),
+		Timeout:   t.Timeout,
 	}
 }
 
@@ -312,6 +341,7 @@ type JobTaskSqlite struct {
 	Name       string `hcl:"name,label"`
 	Path       string `hcl:"path"`
 	DumpToPath string `hcl:"dump_to"`
+	Timeout    string `hcl:"timeout,optional"`
 }
 
 // Paths returns all paths to be backed up from this task.
@@ -338,6 +368,10 @@ func (t JobTaskSqlite) Validate() error {
 		return fmt.Errorf("task %s: dump_to cannot be a directory: %w", t.Name, ErrInvalidConfigValue)
 	}
 
+	if err := validateTimeout("task "+t.Name+" timeout", t.Timeout); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -349,6 +383,7 @@ func (t JobTaskSqlite) GetPreTask() ExecutableTask {
 		Cwd:       ".",
 		OnBackup:  fmt.Sprintf("sqlite3 '%s' '.backup %s'", t.Path, t.DumpToPath),
 		OnRestore: "",
+		Timeout:   t.Timeout,
 	}
 }
 
@@ -360,6 +395,7 @@ func (t JobTaskSqlite) GetPostTask() ExecutableTask {
 		Cwd:       ".",
 		OnBackup:  "",
 		OnRestore: fmt.Sprintf("cp '%s' '%s'", t.DumpToPath, t.Path),
+		Timeout:   t.Timeout,
 	}
 }
 
@@ -372,12 +408,12 @@ type BackupFilesTask struct {
 }
 
 // RunBackup runs the backup task sending data to the repository.
-func (t BackupFilesTask) RunBackup(cfg TaskConfig) error {
+func (t BackupFilesTask) RunBackup(ctx context.Context, cfg TaskConfig) error {
 	if t.BackupOpts == nil {
 		t.BackupOpts = &BackupOpts{} //nolint:exhaustruct
 	}
 
-	if err := cfg.Restic.Backup(cfg.BackupPaths, *t.BackupOpts); err != nil {
+	if err := cfg.Restic.Backup(ctx, cfg.BackupPaths, *t.BackupOpts); err != nil {
 		err = fmt.Errorf("failed backing up paths: %w", err)
 		cfg.Logger.Print(err)
 
@@ -388,7 +424,7 @@ func (t BackupFilesTask) RunBackup(cfg TaskConfig) error {
 }
 
 // RunRestore runs the restore task for the backup, pulling the data from the repository.
-func (t BackupFilesTask) RunRestore(cfg TaskConfig) error {
+func (t BackupFilesTask) RunRestore(ctx context.Context, cfg TaskConfig) error {
 	if t.RestoreOpts == nil {
 		t.RestoreOpts = &RestoreOpts{} //nolint:exhaustruct
 	}
@@ -397,7 +433,7 @@ func (t BackupFilesTask) RunRestore(cfg TaskConfig) error {
 		cfg.RestoreSnapshot = "latest"
 	}
 
-	if err := cfg.Restic.Restore(cfg.RestoreSnapshot, *t.RestoreOpts); err != nil {
+	if err := cfg.Restic.Restore(ctx, cfg.RestoreSnapshot, *t.RestoreOpts); err != nil {
 		err = fmt.Errorf("failed restoring paths: %w", err)
 		cfg.Logger.Print(err)
 
@@ -434,6 +470,7 @@ type JobTask struct {
 	MySQL       []JobTaskMySQL    `hcl:"mysql,block"`
 	Postgres    []JobTaskPostgres `hcl:"postgres,block"`
 	Sqlite      []JobTaskSqlite   `hcl:"sqlite,block"`
+	Timeout     string            `hcl:"timeout,optional"`
 }
 
 // Validate ensures that this tasks configuration is valid.
@@ -443,6 +480,34 @@ func (t JobTask) Validate() error {
 		return fmt.Errorf("task is missing a name: %w", ErrMissingField)
 	}
 
+	if err := validateTimeout("task "+t.Name+" timeout", t.Timeout); err != nil {
+		return err
+	}
+
+	for _, script := range append(t.PreScripts, t.PostScripts...) {
+		if err := script.Validate(); err != nil {
+			return fmt.Errorf("task %s has an invalid script: %w", t.Name, err)
+		}
+	}
+
+	for _, mysql := range t.MySQL {
+		if err := validateTimeout("task "+mysql.Name+" timeout", mysql.Timeout); err != nil {
+			return fmt.Errorf("task %s has an invalid mysql task: %w", t.Name, err)
+		}
+	}
+
+	for _, pg := range t.Postgres {
+		if err := validateTimeout("task "+pg.Name+" timeout", pg.Timeout); err != nil {
+			return fmt.Errorf("task %s has an invalid postgres task: %w", t.Name, err)
+		}
+	}
+
+	for _, sqlite := range t.Sqlite {
+		if err := validateTimeout("task "+sqlite.Name+" timeout", sqlite.Timeout); err != nil {
+			return fmt.Errorf("task %s has an invalid sqlite task: %w", t.Name, err)
+		}
+	}
+
 	return nil
 }
 
@@ -451,15 +516,18 @@ func (t JobTask) GetPreTasks() []ExecutableTask {
 	allTasks := []ExecutableTask{}
 
 	for _, task := range t.MySQL {
+		task.Timeout = cmp.Or(task.Timeout, t.Timeout)
 		allTasks = append(allTasks, task.GetPreTask())
 	}
 
 	for _, task := range t.Sqlite {
+		task.Timeout = cmp.Or(task.Timeout, t.Timeout)
 		allTasks = append(allTasks, task.GetPreTask())
 	}
 
 	for _, exTask := range t.PreScripts {
 		exTask.SetName(t.Name)
+		exTask.Timeout = cmp.Or(exTask.Timeout, t.Timeout)
 		allTasks = append(allTasks, exTask)
 	}
 
@@ -472,14 +540,17 @@ func (t JobTask) GetPostTasks() []ExecutableTask {
 
 	for _, exTask := range t.PostScripts {
 		exTask.SetName(t.Name)
+		exTask.Timeout = cmp.Or(exTask.Timeout, t.Timeout)
 		allTasks = append(allTasks, exTask)
 	}
 
 	for _, task := range t.MySQL {
+		task.Timeout = cmp.Or(task.Timeout, t.Timeout)
 		allTasks = append(allTasks, task.GetPostTask())
 	}
 
 	for _, task := range t.Sqlite {
+		task.Timeout = cmp.Or(task.Timeout, t.Timeout)
 		allTasks = append(allTasks, task.GetPostTask())
 	}
 
@@ -74,6 +74,23 @@ func TestJobTaskScript(t *testing.T) {
 			expectedErr:    nil,
 			expectedOutput: "t OK\nt \n",
 		},
+		{
+			name: "timeout",
+			config: main.TaskConfig{
+				BackupPaths: nil,
+				Env:         nil,
+				Logger:      nil,
+				Restic:      nil,
+			},
+			script: main.JobTaskScript{
+				Cwd:       "./test",
+				OnBackup:  "sleep 5",
+				OnRestore: "sleep 5",
+				Timeout:   "100ms",
+			},
+			expectedErr:    main.ErrTimeout,
+			expectedOutput: "",
+		},
 	}
 
 	for _, c := range cases {
@@ -85,7 +102,7 @@ func TestJobTaskScript(t *testing.T) {
 		t.Run(testCase.name, func(t *testing.T) {
 			t.Parallel()
 
-			actual := testCase.script.RunBackup(testCase.config)
+			actual := testCase.script.RunBackup(t.Context(), testCase.config)
 
 			if !errors.Is(actual, testCase.expectedErr) {
 				t.Errorf("expected error to wrap %v but found %v", testCase.expectedErr, actual)
@@ -1,3 +1,6 @@
+// Limit how many jobs may run at the same time
+max_concurrent_jobs = 2
+
 // A simple backup job
 job "BackupDataDir" {
   schedule = "@daily"
@@ -1,6 +1,9 @@
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 type Set map[string]bool
 
@@ -73,3 +76,30 @@ func maybeAddArgsList(args []string, name string, value []string) []string {
 
 	return args
 }
+
+// validateDuration ensures that value is either empty or a valid duration string.
+func validateDuration(name, value string) error {
+	if value == "" {
+		return nil
+	}
+
+	if _, err := time.ParseDuration(value); err != nil {
+		return fmt.Errorf("%s is not a valid duration: %w: %w", name, err, ErrInvalidConfigValue)
+	}
+
+	return nil
+}
+
+// validateTimeout ensures that value, if set, is a valid duration greater than zero. A timeout of
+// zero or less would expire before anything could run.
+func validateTimeout(name, value string) error {
+	if err := validateDuration(name, value); err != nil {
+		return err
+	}
+
+	if timeout, _ := time.ParseDuration(value); value != "" && timeout <= 0 {
+		return fmt.Errorf("%s must be greater than zero: %w", name, ErrInvalidConfigValue)
+	}
+
+	return nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+)
+
+// VerifyRestoreConfig configures a scheduled drill that restores a snapshot into a temporary
+// directory and validates the restored files with a script.
+type VerifyRestoreConfig struct {
+	Schedule string   `hcl:"schedule"`
+	Snapshot string   `hcl:"snapshot,optional"`
+	Include  []string `hcl:"include,optional"`
+	Script   string   `hcl:"script,optional"`
+}
+
+// Validate ensures that the restore verification has a schedule.
+func (v VerifyRestoreConfig) Validate() error {
+	if v.Schedule == "" {
+		return fmt.Errorf("verify_restore is missing a schedule: %w", ErrMissingField)
+	}
+
+	return nil
+}
+
+// RunVerifyRestore restores a snapshot of the job's files into a temporary directory under
+// JobBaseDir and runs the verify_restore script from within it. The job's tasks are not run, so
+// nothing outside of the temporary directory is modified. The directory is removed afterwards.
+func (j Job) RunVerifyRestore(ctx context.Context) error {
+	if j.VerifyRestore == nil {
+		return fmt.Errorf("job %s has no verify_restore config: %w", j.Name, ErrMissingField)
+	}
+
+	logger := j.Logger()
+
+	if err := os.MkdirAll(JobBaseDir, 0o750); err != nil {
+		return fmt.Errorf("failed creating base dir for job %s: %w", j.Name, err)
+	}
+
+	// The job name may contain a slash, so it is left out of the directory name. It is logged instead.
+	target, err := os.MkdirTemp(JobBaseDir, "verify-restore-")
+	if err != nil {
+		return fmt.Errorf("failed creating restore target for job %s: %w", j.Name, err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(target); err != nil {
+			logger.Printf("ERROR: Failed removing restore target %s: %v", target, err)
+		}
+	}()
+
+	restoreOpts := RestoreOpts{} //nolint:exhaustruct
+	if j.Backup.RestoreOpts != nil {
+		restoreOpts = *j.Backup.RestoreOpts
+	}
+
+	restoreOpts.Target = target
+	if len(j.VerifyRestore.Include) > 0 {
+		restoreOpts.Include = j.VerifyRestore.Include
+	}
+
+	snapshot := j.VerifyRestore.Snapshot
+	if snapshot == "" {
+		snapshot = "latest"
+	}
+
+	logger.Printf("Verifying restore of snapshot %s into %s", snapshot, target)
+
+	if err := j.NewRestic().Restore(ctx, snapshot, restoreOpts); err != nil {
+		return fmt.Errorf("failed restoring snapshot %s for verification of job %s: %w", snapshot, j.Name, err)
+	}
+
+	if j.VerifyRestore.Script == "" {
+		return nil
+	}
+
+	env := map[string]string{}
+	maps.Copy(env, j.Config.Env)
+	env["RESTORE_TARGET"] = target
+	env["RESTORE_SNAPSHOT"] = snapshot
+
+	if err := RunShell(ctx, j.VerifyRestore.Script, target, env, GetChildLogger(logger, "verify_restore")); err != nil {
+		return fmt.Errorf("restore verification script failed for job %s: %w: %w", j.Name, err, ErrTask)
+	}
+
+	return nil
+}
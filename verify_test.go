@@ -0,0 +1,97 @@
+package main_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	main "git.iamthefij.com/iamthefij/restic-scheduler"
+	"github.com/stretchr/testify/assert"
+)
+
+// tempJobBaseDir sets JobBaseDir to a new temporary directory for the rest of the test. Tests
+// using it can't run in parallel.
+func tempJobBaseDir(t *testing.T) string {
+	t.Helper()
+
+	previous := main.JobBaseDir
+	main.JobBaseDir = t.TempDir()
+
+	t.Cleanup(func() { main.JobBaseDir = previous })
+
+	return main.JobBaseDir
+}
+
+// TestRunVerifyRestore replaces restic and JobBaseDir, so it can't run in parallel.
+func TestRunVerifyRestore(t *testing.T) {
+	// Restores write a file into the target
+	fakeRestic(t, "case \"$*\" in\n"+
+		"*restore*)\n"+
+		"  while [ $# -gt 0 ]; do\n"+
+		"    if [ \"$1\" = --target ]; then echo restored > \"$2/file.txt\"; fi\n"+
+		"    shift\n"+
+		"  done ;;\n"+
+		"esac\n")
+
+	baseDir := tempJobBaseDir(t)
+	output := filepath.Join(t.TempDir(), "output")
+
+	cases := []struct {
+		name           string
+		script         string
+		expectedStatus string
+	}{
+		{
+			name:           "Passing script",
+			script:         `printf '%s\n' "$RESTORE_TARGET" "$RESTORE_SNAPSHOT" "$(pwd)" "$(cat file.txt)" > ` + output,
+			expectedStatus: main.JobStatusSuccess,
+		},
+		{
+			name:           "Failing script",
+			script:         "exit 1",
+			expectedStatus: main.JobStatusFailure,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// Job names may contain a slash
+			job := main.Job{ //nolint:exhaustruct
+				Name:     uniqueJobName("TestRunVerifyRestore/" + c.name),
+				Schedule: "@daily",
+				Config:   ValidResticConfig(),
+				VerifyRestore: &main.VerifyRestoreConfig{
+					Schedule: "@weekly",
+					Snapshot: "abc123",
+					Include:  nil,
+					Script:   c.script,
+				},
+			}
+
+			result := job.RunWithInfo(context.Background(), main.RunInfo{JobType: main.JobTypeRestoreVerify}) //nolint:exhaustruct
+
+			AssertEqual(t, "unexpected job type", main.JobTypeRestoreVerify, result.JobType)
+			AssertEqual(t, "unexpected status", c.expectedStatus, result.Status)
+
+			// The restore target is removed afterwards
+			entries, err := os.ReadDir(baseDir)
+			AssertEqualFail(t, "unexpected error reading base dir", nil, err)
+			assert.Empty(t, entries)
+		})
+	}
+
+	written, err := os.ReadFile(output)
+	AssertEqualFail(t, "unexpected error reading script output", nil, err)
+
+	fields := strings.Split(strings.TrimSpace(string(written)), "\n")
+	AssertEqualFail(t, "unexpected script output", 4, len(fields))
+
+	target, snapshot, cwd, restored := fields[0], fields[1], fields[2], fields[3]
+
+	AssertEqual(t, "unexpected target dir", baseDir, filepath.Dir(target))
+	AssertEqual(t, "unexpected snapshot", "abc123", snapshot)
+	AssertEqual(t, "unexpected working dir", target, cwd)
+	AssertEqual(t, "unexpected restored file", "restored", restored)
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// WindowPolicyDefer delays runs scheduled outside of a job's windows until the next window starts.
+	WindowPolicyDefer = "defer"
+	// WindowPolicySkip skips runs scheduled outside of a job's windows.
+	WindowPolicySkip = "skip"
+
+	// SkipReasonWindow is the reason recorded when a run is skipped because it is outside of the job's windows.
+	SkipReasonWindow = "window"
+
+	// windowSearchLimit is how far ahead to look for the start or end of a window. Windows repeat
+	// weekly, so anything not found within a week and a day will never be found.
+	windowSearchLimit = 8 * 24 * time.Hour
+)
+
+var (
+	// ErrWindowClosed is the cause of a run being cancelled because its window ended.
+	ErrWindowClosed = errors.New("window closed")
+
+	// WindowPolicies are the accepted values for a job's window_policy.
+	WindowPolicies = NewSetFrom([]string{"", WindowPolicyDefer, WindowPolicySkip})
+
+	weekdays = map[string]time.Weekday{
+		"sun": time.Sunday,
+		"mon": time.Monday,
+		"tue": time.Tuesday,
+		"wed": time.Wednesday,
+		"thu": time.Thursday,
+		"fri": time.Friday,
+		"sat": time.Saturday,
+	}
+)
+
+// TimeWindow is a recurring period of time on some days of the week, such as 22:00 to 06:00 on
+// weekdays. If end is before start, the window continues past midnight into the next day. If they
+// are equal, the window lasts a whole day. Days refer to the day the window starts.
+type TimeWindow struct {
+	Days  []string `hcl:"days,optional"`
+	Start string   `hcl:"start"`
+	End   string   `hcl:"end"`
+}
+
+// Validate ensures that the window has valid days and times.
+func (w TimeWindow) Validate() error {
+	for _, day := range w.Days {
+		if _, ok := weekdays[strings.ToLower(day)]; !ok {
+			return fmt.Errorf("window day %q must be one of sun, mon, tue, wed, thu, fri or sat: %w", day, ErrInvalidConfigValue)
+		}
+	}
+
+	if _, err := parseClock(w.Start); err != nil {
+		return fmt.Errorf("window start %q must be a time like 22:00: %w", w.Start, ErrInvalidConfigValue)
+	}
+
+	if _, err := parseClock(w.End); err != nil {
+		return fmt.Errorf("window end %q must be a time like 06:00: %w", w.End, ErrInvalidConfigValue)
+	}
+
+	return nil
+}
+
+// Contains returns true if t, in its own location, falls within the window.
+func (w TimeWindow) Contains(t time.Time) bool {
+	start, _ := parseClock(w.Start)
+	end, _ := parseClock(w.End)
+	clock := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+
+	if start < end {
+		return w.onDay(t.Weekday()) && clock >= start && clock < end
+	}
+
+	if clock >= start {
+		return w.onDay(t.Weekday())
+	}
+
+	// Windows that wrap past midnight continue from the previous day
+	return clock < end && w.onDay(t.AddDate(0, 0, -1).Weekday())
+}
+
+// onDay returns true if the window starts on the provided day.
+func (w TimeWindow) onDay(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+
+	for _, name := range w.Days {
+		if weekdays[strings.ToLower(name)] == day {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseClock parses a time of day like 22:00 as the duration since midnight.
+func parseClock(value string) (time.Duration, error) {
+	clock, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("failed parsing time of day %q: %w", value, err)
+	}
+
+	return time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute, nil
+}
+
+// HasWindows returns true if the job has any allowed windows or blackouts.
+func (j Job) HasWindows() bool {
+	return len(j.AllowedWindows) > 0 || len(j.Blackouts) > 0
+}
+
+// InWindow returns true if the job may run at t. That is when t is within one of the job's allowed
+// windows, if it has any, and is not within any of its blackouts. Windows are evaluated in the
+// job's time zone.
+func (j Job) InWindow(t time.Time) bool {
+	t = t.In(j.Location())
+
+	for _, blackout := range j.Blackouts {
+		if blackout.Contains(t) {
+			return false
+		}
+	}
+
+	if len(j.AllowedWindows) == 0 {
+		return true
+	}
+
+	for _, window := range j.AllowedWindows {
+		if window.Contains(t) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NextWindowStart returns the first time from t onwards that the job may run. It returns the zero
+// time if the job's windows never allow it to run.
+func (j Job) NextWindowStart(t time.Time) time.Time {
+	return j.searchWindow(t, true)
+}
+
+// WindowEnd returns the first time from t onwards that the job may no longer run. It returns the
+// zero time if the job's windows always allow it to run.
+func (j Job) WindowEnd(t time.Time) time.Time {
+	return j.searchWindow(t, false)
+}
+
+// searchWindow steps through time from t, a minute at a time, to find when InWindow first returns
+// inWindow. Window boundaries are always on the minute, so none are missed.
+func (j Job) searchWindow(t time.Time, inWindow bool) time.Time {
+	// Avoid loading the time zone for every step
+	j.location = j.Location()
+
+	if j.InWindow(t) == inWindow {
+		return t
+	}
+
+	limit := t.Add(windowSearchLimit)
+
+	for candidate := t.Truncate(time.Minute).Add(time.Minute); candidate.Before(limit); candidate = candidate.Add(time.Minute) {
+		if j.InWindow(candidate) == inWindow {
+			return candidate
+		}
+	}
+
+	return time.Time{}
+}
+
+// WindowDeadline returns when a run started at t must be cancelled because its window ends. It
+// returns the zero time if the job doesn't have cancel_at_window_end set, t isn't within a window or
+// the window never ends.
+func (j Job) WindowDeadline(t time.Time) time.Time {
+	if !j.CancelAtWindowEnd || !j.HasWindows() || !j.InWindow(t) {
+		return time.Time{}
+	}
+
+	return j.WindowEnd(t)
+}
+
+// withWindowDeadline returns a child of ctx that is cancelled with ErrWindowClosed once the job's
+// current window ends, if the job has cancel_at_window_end set.
+func (j Job) withWindowDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	end := j.WindowDeadline(time.Now())
+	if end.IsZero() {
+		return context.WithCancel(ctx)
+	}
+
+	j.Logger().Printf("Run will be cancelled if still running when its window ends at %s", end.Format(time.RFC3339))
+
+	return context.WithDeadlineCause(ctx, end, ErrWindowClosed)
+}
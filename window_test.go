@@ -0,0 +1,387 @@
+package main_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	main "git.iamthefij.com/iamthefij/restic-scheduler"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeWindowValidate(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name        string
+		window      main.TimeWindow
+		expectedErr error
+	}{
+		{
+			name:        "Valid",
+			window:      main.TimeWindow{Days: []string{"mon", "Fri"}, Start: "22:00", End: "06:00"},
+			expectedErr: nil,
+		},
+		{
+			name:        "Invalid day",
+			window:      main.TimeWindow{Days: []string{"monday"}, Start: "22:00", End: "06:00"},
+			expectedErr: main.ErrInvalidConfigValue,
+		},
+		{
+			name:        "Invalid start",
+			window:      main.TimeWindow{Days: nil, Start: "10pm", End: "06:00"},
+			expectedErr: main.ErrInvalidConfigValue,
+		},
+		{
+			name:        "Invalid end",
+			window:      main.TimeWindow{Days: nil, Start: "22:00", End: "24:00"},
+			expectedErr: main.ErrInvalidConfigValue,
+		},
+	}
+
+	for _, c := range cases {
+		testCase := c
+
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			actual := testCase.window.Validate()
+			if !errors.Is(actual, testCase.expectedErr) {
+				t.Errorf("expected %v but found %v", testCase.expectedErr, actual)
+			}
+		})
+	}
+}
+
+func TestJobInWindow(t *testing.T) {
+	t.Parallel()
+
+	// 2026-03-09 is a Monday
+	monday := func(hour, minute int) time.Time {
+		return time.Date(2026, time.March, 9, hour, minute, 0, 0, time.UTC)
+	}
+
+	nightly := []main.TimeWindow{{Days: []string{"mon", "tue", "wed", "thu", "fri"}, Start: "22:00", End: "06:00"}}
+	businessHours := []main.TimeWindow{{Days: nil, Start: "09:00", End: "17:00"}}
+
+	cases := []struct {
+		name      string
+		allowed   []main.TimeWindow
+		blackouts []main.TimeWindow
+		time      time.Time
+		expected  bool
+	}{
+		{
+			name:      "No windows",
+			allowed:   nil,
+			blackouts: nil,
+			time:      monday(12, 0),
+			expected:  true,
+		},
+		{
+			name:      "Within window",
+			allowed:   nightly,
+			blackouts: nil,
+			time:      monday(23, 0),
+			expected:  true,
+		},
+		{
+			name:      "Before window",
+			allowed:   nightly,
+			blackouts: nil,
+			time:      monday(21, 59),
+			expected:  false,
+		},
+		{
+			name:      "After midnight of window started on Friday",
+			allowed:   nightly,
+			blackouts: nil,
+			time:      time.Date(2026, time.March, 14, 5, 59, 0, 0, time.UTC),
+			expected:  true,
+		},
+		{
+			name:      "After midnight of window not started on Sunday",
+			allowed:   nightly,
+			blackouts: nil,
+			time:      monday(1, 0),
+			expected:  false,
+		},
+		{
+			name:      "Within blackout",
+			allowed:   nil,
+			blackouts: businessHours,
+			time:      monday(9, 0),
+			expected:  false,
+		},
+		{
+			name:      "End of blackout",
+			allowed:   nil,
+			blackouts: businessHours,
+			time:      monday(17, 0),
+			expected:  true,
+		},
+	}
+
+	for _, c := range cases {
+		testCase := c
+
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			job := main.Job{ //nolint:exhaustruct
+				Name:           "Test job",
+				Schedule:       "@daily",
+				Timezone:       "UTC",
+				AllowedWindows: testCase.allowed,
+				Blackouts:      testCase.blackouts,
+			}
+
+			AssertEqual(t, "in window", testCase.expected, job.InWindow(testCase.time))
+		})
+	}
+}
+
+func TestJobWindowBoundaries(t *testing.T) {
+	t.Parallel()
+
+	job := main.Job{ //nolint:exhaustruct
+		Name:           "Test job",
+		Schedule:       "@daily",
+		Timezone:       "America/New_York",
+		AllowedWindows: []main.TimeWindow{{Days: nil, Start: "22:00", End: "06:00"}},
+		Blackouts:      []main.TimeWindow{{Days: []string{"sat"}, Start: "00:00", End: "00:00"}},
+	}
+
+	location := job.Location()
+	format := "Mon 2006-01-02 15:04"
+
+	// Friday afternoon in New York
+	now := time.Date(2026, time.March, 13, 15, 30, 0, 0, location)
+
+	AssertEqual(t, "next window start", "Fri 2026-03-13 22:00", job.NextWindowStart(now).In(location).Format(format))
+	AssertEqual(t, "window end cut short by blackout", "Sat 2026-03-14 00:00", job.WindowEnd(now.Add(7*time.Hour)).In(location).Format(format))
+
+	// Saturday is blacked out, so the next window is the part of Saturday night's window after midnight
+	saturday := time.Date(2026, time.March, 14, 12, 0, 0, 0, location)
+	AssertEqual(t, "next window after blackout", "Sun 2026-03-15 00:00", job.NextWindowStart(saturday).In(location).Format(format))
+
+	AssertEqual(t, "start when already in window", now.Add(7*time.Hour), job.NextWindowStart(now.Add(7*time.Hour)))
+
+	never := main.Job{ //nolint:exhaustruct
+		Name:      "Never",
+		Schedule:  "@daily",
+		Blackouts: []main.TimeWindow{{Days: nil, Start: "00:00", End: "00:00"}},
+	}
+
+	AssertEqual(t, "no window start", true, never.NextWindowStart(now).IsZero())
+	AssertEqual(t, "no window end", true, main.Job{}.WindowEnd(now).IsZero()) //nolint:exhaustruct
+}
+
+func TestJobWindowDeadline(t *testing.T) {
+	t.Parallel()
+
+	windows := []main.TimeWindow{{Days: nil, Start: "22:00", End: "06:00"}}
+	now := time.Date(2026, time.March, 13, 23, 0, 0, 0, time.Local)
+
+	cases := []struct {
+		name     string
+		job      main.Job
+		expected time.Time
+	}{
+		{
+			name:     "Not cancelled at window end",
+			job:      main.Job{AllowedWindows: windows}, //nolint:exhaustruct
+			expected: time.Time{},
+		},
+		{
+			name:     "No windows",
+			job:      main.Job{CancelAtWindowEnd: true}, //nolint:exhaustruct
+			expected: time.Time{},
+		},
+		{
+			name:     "Cancelled at window end",
+			job:      main.Job{AllowedWindows: windows, CancelAtWindowEnd: true}, //nolint:exhaustruct
+			expected: time.Date(2026, time.March, 14, 6, 0, 0, 0, time.Local),
+		},
+		{
+			name: "Outside window",
+			job: main.Job{ //nolint:exhaustruct
+				AllowedWindows:    []main.TimeWindow{{Days: nil, Start: "01:00", End: "05:00"}},
+				CancelAtWindowEnd: true,
+			},
+			expected: time.Time{},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+
+			AssertEqual(t, "unexpected deadline", c.expected, c.job.WindowDeadline(now))
+		})
+	}
+}
+
+// TestJobCancelledAtWindowEnd replaces restic with a script that hangs, so it can't run in parallel.
+func TestJobCancelledAtWindowEnd(t *testing.T) {
+	fakeRestic(t, "case \"$*\" in\n*snapshots*) echo '[]' ;;\n*) sleep 10 ;;\nesac\n")
+
+	job := main.Job{ //nolint:exhaustruct
+		Name:     uniqueJobName("TestJobCancelledAtWindowEnd"),
+		Schedule: "@daily",
+		Config:   ValidResticConfig(),
+	}
+
+	ctx, cancel := context.WithDeadlineCause(context.Background(), time.Now().Add(200*time.Millisecond), main.ErrWindowClosed)
+	defer cancel()
+
+	result := job.RunWithInfo(ctx, main.RunInfo{JobType: main.JobTypeCheck}) //nolint:exhaustruct
+
+	AssertEqual(t, "unexpected status", main.JobStatusCancelled, result.Status)
+	assert.ErrorIs(t, result.LastError, main.ErrWindowClosed)
+}
+
+// TestSchedulerWindowPolicy replaces restic and records history, so it can't run in parallel.
+func TestSchedulerWindowPolicy(t *testing.T) {
+	fakeRestic(t, "exit 0\n")
+
+	store := recordHistory(t)
+
+	// A window that starts in a couple of minutes so that runs now are outside of it
+	start := time.Now().Truncate(time.Minute).Add(2 * time.Minute)
+	window := main.TimeWindow{Days: nil, Start: start.Format("15:04"), End: start.Add(time.Minute).Format("15:04")}
+
+	deferJob := main.Job{ //nolint:exhaustruct
+		Name:           uniqueJobName("TestSchedulerWindowDefer"),
+		Schedule:       "@daily",
+		CatchUp:        true,
+		Config:         ValidResticConfig(),
+		AllowedWindows: []main.TimeWindow{window},
+		WindowPolicy:   main.WindowPolicyDefer,
+	}
+	skipJob := deferJob
+	skipJob.Name = uniqueJobName("TestSchedulerWindowSkip")
+	skipJob.WindowPolicy = main.WindowPolicySkip
+
+	sched := main.NewScheduler()
+
+	err := sched.Start([]main.Job{deferJob, skipJob})
+	AssertEqualFail(t, "unexpected error starting scheduler", nil, err)
+
+	defer sched.StopGraceful(0)
+
+	missed := time.Now().Add(-48 * time.Hour)
+
+	// Runs outside of the window are skipped with the skip policy
+	assert.True(t, sched.CatchUp(skipJob, missed))
+
+	records := waitForHistory(t, store, skipJob.Name, 1)
+	AssertEqual(t, "unexpected status", main.JobStatusSkipped, records[0].Status)
+	AssertEqual(t, "unexpected message", "skipped: "+main.SkipReasonWindow, records[0].Message)
+
+	// and deferred until the window starts with the defer policy
+	assert.True(t, sched.CatchUp(deferJob, missed))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for sched.Active().Jobs[deferJob.Name].State != main.JobStateDeferred && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	active := sched.Active()
+	AssertEqual(t, "unexpected state", main.JobStateDeferred, active.Jobs[deferJob.Name].State)
+	assert.True(t, start.Equal(active.DeferredJobs[deferJob.Name]), "expected deferred until %s", start)
+
+	// Deferred runs are dropped once the scheduler stops
+	sched.StopGraceful(0)
+
+	records, err = store.Read(deferJob.Name, 0)
+	AssertEqualFail(t, "unexpected error reading history", nil, err)
+	assert.Empty(t, records)
+	assert.Empty(t, sched.Active().DeferredJobs)
+}
+
+// TestSchedulerWindowEndsWhileQueued replaces restic and records history, so it can't run in
+// parallel. It waits for the end of the current minute for a window to end.
+func TestSchedulerWindowEndsWhileQueued(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skip waiting for a window to end when running short tests")
+	}
+
+	// Leave enough time to queue runs before the window ends
+	end := time.Now().Truncate(time.Minute).Add(time.Minute)
+	if time.Until(end) < 5*time.Second {
+		time.Sleep(time.Until(end))
+
+		end = end.Add(time.Minute)
+	}
+
+	// Checks hold the repository until after the window has ended
+	holdFor := int(time.Until(end).Seconds()) + 2
+	fakeRestic(t, "case \"$*\" in\n"+
+		"*snapshots*) echo '[]' ;;\n"+
+		"*check*) sleep "+strconv.Itoa(holdFor)+" ;;\n"+
+		"esac\n")
+
+	store := recordHistory(t)
+
+	window := main.TimeWindow{Days: nil, Start: end.Add(-time.Minute).Format("15:04"), End: end.Format("15:04")}
+	config := &main.ResticConfig{Passphrase: "shh", Repo: "/repo/window-queued"} //nolint:exhaustruct
+
+	holdJob := main.Job{ //nolint:exhaustruct
+		Name:     uniqueJobName("TestWindowQueuedHold"),
+		Schedule: "@daily",
+		Config:   config,
+	}
+	skipJob := main.Job{ //nolint:exhaustruct
+		Name:              uniqueJobName("TestWindowQueuedSkip"),
+		Schedule:          "@daily",
+		CatchUp:           true,
+		Config:            config,
+		AllowedWindows:    []main.TimeWindow{window},
+		WindowPolicy:      main.WindowPolicySkip,
+		CancelAtWindowEnd: true,
+	}
+	deferJob := skipJob
+	deferJob.Name = uniqueJobName("TestWindowQueuedDefer")
+	deferJob.WindowPolicy = main.WindowPolicyDefer
+
+	sched := main.NewScheduler()
+
+	err := sched.Start([]main.Job{holdJob, skipJob, deferJob})
+	AssertEqualFail(t, "unexpected error starting scheduler", nil, err)
+
+	defer sched.StopGraceful(0)
+
+	_, err = sched.RunNow(holdJob.Name, main.JobTypeCheck)
+	AssertEqualFail(t, "unexpected error queueing run", nil, err)
+
+	// Both runs start within their window, but wait for the repository until after it has ended
+	missed := time.Now().Add(-48 * time.Hour)
+	assert.True(t, sched.CatchUp(skipJob, missed))
+	assert.True(t, sched.CatchUp(deferJob, missed))
+
+	assert.Eventually(t, func() bool {
+		return len(sched.Active().QueuedJobs) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	var records []main.RunRecord
+
+	assert.Eventually(t, func() bool {
+		records, err = store.Read(skipJob.Name, 0)
+
+		return err == nil && len(records) > 0
+	}, time.Until(end)+10*time.Second, 100*time.Millisecond)
+	AssertEqualFail(t, "unexpected number of records", 1, len(records))
+	AssertEqual(t, "unexpected status", main.JobStatusSkipped, records[0].Status)
+	AssertEqual(t, "unexpected message", "skipped: "+main.SkipReasonWindow, records[0].Message)
+
+	assert.Eventually(t, func() bool {
+		return sched.Active().Jobs[deferJob.Name].State == main.JobStateDeferred
+	}, 10*time.Second, 10*time.Millisecond)
+
+	records, err = store.Read(deferJob.Name, 0)
+	AssertEqualFail(t, "unexpected error reading history", nil, err)
+	assert.Empty(t, records)
+}